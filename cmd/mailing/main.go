@@ -9,7 +9,6 @@ import (
 	"mailing/internal/mailing"
 	"mailing/internal/mailing/api/sender"
 	"mailing/internal/mailing/storage/postgres"
-	"net/http"
 	"os"
 	"sync"
 	"time"
@@ -36,7 +35,6 @@ func run() error {
 	// Collecting prerequisites.
 	gin.SetMode(gin.ReleaseMode)
 	ctx := context.Background()
-	client := &http.Client{}
 	logger, err := zap.NewDevelopment()
 	if err != nil {
 		return errors.Wrap(err, "initialising logger")
@@ -65,10 +63,12 @@ func run() error {
 
 	// Creating sender realisation via sender API.
 	senderConfig := config.NewSenderConfig()
-	sender := sender.New(client, senderConfig)
+	sender := sender.New(sender.NewHTTPClient(senderConfig), senderConfig)
+	// Sending batches left in queue before exit.
+	defer sender.Close()
 
 	// Creating mailing service from collected dependencies.
-	service := mailing.New(storage, sender)
+	service := mailing.New(storage, sender, config.NewMailingConfig())
 
 	// Creating controllers.
 	router := gin.Default()
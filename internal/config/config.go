@@ -1,6 +1,11 @@
 package config
 
-import "os"
+import (
+	"os"
+	"strconv"
+	"strings"
+	"time"
+)
 
 // DBConfig is config with sensitive data, needed for working with db.
 type DBConfig struct {
@@ -24,16 +29,112 @@ func NewDBConfig() *DBConfig {
 
 // SenderConfig is config with sensitive data, needed for working with sender.
 type SenderConfig struct {
-	JWT string
+	// URL is the base URL of sender API.
+	URL string
+	// JWTs are sender API tokens, the next one is used when the current one expires or is rejected.
+	JWTs []string
+	// JWTExpiryWarning is how long before token expiry warnings are logged.
+	JWTExpiryWarning time.Duration
+	// JWTRejectCooldown is how long token rejected by sender API is not used, it is retried afterwards.
+	JWTRejectCooldown time.Duration
+	// Timeout is the time limit for a single request to sender API.
+	Timeout time.Duration
+	// MaxIdleConns is the maximum number of idle keep-alive connections kept in the pool.
+	MaxIdleConns int
+	// MaxConnsPerHost is the maximum number of connections to sender API, 0 means no limit.
+	MaxConnsPerHost int
+	// IdleConnTimeout is the time after which idle connection is closed.
+	IdleConnTimeout time.Duration
+	// TLSHandshakeTimeout is the time limit for TLS handshake.
+	TLSHandshakeTimeout time.Duration
+	// TLSInsecureSkipVerify disables verification of sender API certificate, never use it in production.
+	TLSInsecureSkipVerify bool
+	// HTTP2 enables HTTP/2 if sender API supports it.
+	HTTP2 bool
+	// BatchPath is the path of sender API batch endpoint, batch mode is disabled if it is empty.
+	BatchPath string
+	// BatchSize is the maximum amount of messages sent in one batch.
+	BatchSize int
+	// BatchWait is the maximum time message waits for its batch to fill up.
+	BatchWait time.Duration
+	// RateLimit is the maximum amount of messages sent per second, 0 means no limit.
+	RateLimit int
 }
 
 // NewSenderConfig returns SenderConfig with sensitive data, needed for working with sender.
 func NewSenderConfig() *SenderConfig {
 	return &SenderConfig{
-		JWT: os.Getenv("SENDER_JWT"),
+		URL:                   getEnvString("SENDER_URL", "https://probe.fbrq.cloud/v1"),
+		JWTs:                  getEnvList("SENDER_JWT"),
+		JWTExpiryWarning:      getEnvDuration("SENDER_JWT_EXPIRY_WARNING", 72*time.Hour),
+		JWTRejectCooldown:     getEnvDuration("SENDER_JWT_REJECT_COOLDOWN", time.Minute),
+		Timeout:               getEnvDuration("SENDER_TIMEOUT", 10*time.Second),
+		MaxIdleConns:          getEnvInt("SENDER_MAX_IDLE_CONNS", 100),
+		MaxConnsPerHost:       getEnvInt("SENDER_MAX_CONNS_PER_HOST", 100),
+		IdleConnTimeout:       getEnvDuration("SENDER_IDLE_CONN_TIMEOUT", 90*time.Second),
+		TLSHandshakeTimeout:   getEnvDuration("SENDER_TLS_HANDSHAKE_TIMEOUT", 10*time.Second),
+		TLSInsecureSkipVerify: getEnvBool("SENDER_TLS_INSECURE_SKIP_VERIFY", false),
+		HTTP2:                 getEnvBool("SENDER_HTTP2", true),
+		BatchPath:             os.Getenv("SENDER_BATCH_PATH"),
+		BatchSize:             getEnvInt("SENDER_BATCH_SIZE", 100),
+		BatchWait:             getEnvDuration("SENDER_BATCH_WAIT", 100*time.Millisecond),
+		RateLimit:             getEnvInt("SENDER_RATE_LIMIT", 0),
 	}
 }
 
+// MailingConfig is config of mailing service.
+type MailingConfig struct {
+	// ShortLinkBaseURL is the public URL of short link redirect endpoint, e.g. https://example.com/l.
+	// URLs in mailing's text aren't shortened if it is empty.
+	ShortLinkBaseURL string
+	// StopKeywords are replies, that opt client out, they are matched ignoring case and surrounding punctuation.
+	StopKeywords []string
+	// StopConfirmation is the text sent to client, that opted out.
+	StopConfirmation string
+	// ConsentKeywords are replies, that confirm double opt-in, they are matched like StopKeywords.
+	ConsentKeywords []string
+	// ConsentRequestText is the text of double opt-in request.
+	ConsentRequestText string
+	// ConsentConfirmURL is the public URL of consent confirmation endpoint, e.g. https://example.com/consent.
+	// Confirmation link is appended to double opt-in request if it is set.
+	ConsentConfirmURL string
+	// ConsentTimeout is how long double opt-in waits for confirmation.
+	ConsentTimeout time.Duration
+	// FrequencyCap is the maximum amount of mailing messages client gets in FrequencyCapWindow, 0 means no limit.
+	FrequencyCap int
+	// FrequencyCapWindow is the period FrequencyCap applies to.
+	FrequencyCapWindow time.Duration
+	// MinMessageGap is the minimum time between mailing messages to the same client, 0 means no limit.
+	MinMessageGap time.Duration
+	// Provider is the name of SMS provider messages are sent via, it selects provider's prices from price table.
+	Provider string
+}
+
+// NewMailingConfig returns MailingConfig.
+func NewMailingConfig() *MailingConfig {
+	c := &MailingConfig{
+		ShortLinkBaseURL: strings.TrimSuffix(os.Getenv("SHORT_LINK_BASE_URL"), "/"),
+		StopKeywords:     getEnvList("STOP_KEYWORDS"),
+		StopConfirmation: getEnvString("STOP_CONFIRMATION", "You are unsubscribed and will receive no more messages."),
+		ConsentKeywords:  getEnvList("CONSENT_KEYWORDS"),
+		ConsentRequestText: getEnvString("CONSENT_REQUEST_TEXT",
+			"Reply YES to confirm you want to receive our messages."),
+		ConsentConfirmURL:  strings.TrimSuffix(os.Getenv("CONSENT_CONFIRM_URL"), "/"),
+		ConsentTimeout:     getEnvDuration("CONSENT_TIMEOUT", 72*time.Hour),
+		FrequencyCap:       getEnvInt("FREQUENCY_CAP", 0),
+		FrequencyCapWindow: getEnvDuration("FREQUENCY_CAP_WINDOW", 24*time.Hour),
+		MinMessageGap:      getEnvDuration("MIN_MESSAGE_GAP", 0),
+		Provider:           os.Getenv("SMS_PROVIDER"),
+	}
+	if len(c.StopKeywords) == 0 {
+		c.StopKeywords = []string{"STOP", "UNSUBSCRIBE", "CANCEL", "END", "QUIT"}
+	}
+	if len(c.ConsentKeywords) == 0 {
+		c.ConsentKeywords = []string{"YES"}
+	}
+	return c
+}
+
 // HTTPConfig is config with sensitive data, needed for rest API.
 type HTTPConfig struct {
 	Host string
@@ -45,3 +146,51 @@ func NewHTTPConfig() *HTTPConfig {
 		Host: os.Getenv("HTTP_HOST"),
 	}
 }
+
+// getEnvString returns value of environment variable or def if it is not set.
+func getEnvString(key string, def string) string {
+	v := os.Getenv(key)
+	if v == "" {
+		return def
+	}
+	return v
+}
+
+// getEnvList returns comma separated values of environment variable.
+func getEnvList(key string) []string {
+	values := []string{}
+	for _, v := range strings.Split(os.Getenv(key), ",") {
+		v = strings.TrimSpace(v)
+		if v != "" {
+			values = append(values, v)
+		}
+	}
+	return values
+}
+
+// getEnvInt returns integer value of environment variable or def if it is not set or malformed.
+func getEnvInt(key string, def int) int {
+	v, err := strconv.Atoi(os.Getenv(key))
+	if err != nil {
+		return def
+	}
+	return v
+}
+
+// getEnvBool returns boolean value of environment variable or def if it is not set or malformed.
+func getEnvBool(key string, def bool) bool {
+	v, err := strconv.ParseBool(os.Getenv(key))
+	if err != nil {
+		return def
+	}
+	return v
+}
+
+// getEnvDuration returns duration value of environment variable or def if it is not set or malformed.
+func getEnvDuration(key string, def time.Duration) time.Duration {
+	v, err := time.ParseDuration(os.Getenv(key))
+	if err != nil {
+		return def
+	}
+	return v
+}
@@ -10,11 +10,13 @@ import (
 // Data transfer objects. For reference see models package.
 
 type clientDTO struct {
-	ID            int64  `json:"id"`
-	PhoneNumber   int64  `json:"phoneNumber"`
-	PhoneOperator int    `json:"phoneOperator"`
-	Tag           string `json:"tag"`
-	Timezone      string `json:"timezone"`
+	ID            int64          `json:"id"`
+	PhoneNumber   int64          `json:"phoneNumber"`
+	PhoneOperator int            `json:"phoneOperator"`
+	Tags          []string       `json:"tags"`
+	Timezone      string         `json:"timezone"`
+	Attributes    map[string]any `json:"attributes"`
+	Locale        string         `json:"locale"`
 }
 
 func clientToDTO(c *models.Client) *clientDTO {
@@ -22,64 +24,391 @@ func clientToDTO(c *models.Client) *clientDTO {
 		ID:            c.ID,
 		PhoneNumber:   c.PhoneNumber,
 		PhoneOperator: c.PhoneOperator,
-		Tag:           c.Tag,
+		Tags:          c.Tags,
 		Timezone:      c.Timezone,
+		Attributes:    c.Attributes,
+		Locale:        c.Locale,
 	}
 }
 
 type clientUpdateDTO struct {
-	PhoneNumber   int64  `json:"phoneNumber"`
+	PhoneNumber   int64          `json:"phoneNumber"`
+	PhoneOperator int            `json:"phoneOperator"`
+	Tags          []string       `json:"tags"`
+	Timezone      string         `json:"timezone"`
+	Attributes    map[string]any `json:"attributes"`
+	Locale        string         `json:"locale"`
+}
+
+type attributeDefinitionDTO struct {
+	Name          string   `json:"name"`
+	Type          string   `json:"type"`
+	Required      bool     `json:"required"`
+	AllowedValues []string `json:"allowedValues"`
+}
+
+func attributeDefinitionToDTO(d *models.AttributeDefinition) *attributeDefinitionDTO {
+	return &attributeDefinitionDTO{
+		Name:          d.Name,
+		Type:          string(d.Type),
+		Required:      d.Required,
+		AllowedValues: d.AllowedValues,
+	}
+}
+
+type suppressionDTO struct {
+	ID          int64     `json:"id"`
+	ClientID    *int64    `json:"clientID"`
+	PhoneNumber *int64    `json:"phoneNumber"`
+	Reason      string    `json:"reason"`
+	Source      string    `json:"source"`
+	CreatedAt   time.Time `json:"createdAt"`
+}
+
+func suppressionToDTO(s *models.Suppression) *suppressionDTO {
+	return &suppressionDTO{
+		ID:          s.ID,
+		ClientID:    s.ClientID,
+		PhoneNumber: s.PhoneNumber,
+		Reason:      s.Reason,
+		Source:      s.Source,
+		CreatedAt:   s.CreatedAt,
+	}
+}
+
+type priceDTO struct {
+	ID            int64  `json:"id"`
 	PhoneOperator int    `json:"phoneOperator"`
-	Tag           string `json:"tag"`
-	Timezone      string `json:"timezone"`
+	Provider      string `json:"provider"`
+	Price         int64  `json:"price"`
+}
+
+func priceToDTO(p *models.Price) *priceDTO {
+	return &priceDTO{
+		ID:            p.ID,
+		PhoneOperator: p.PhoneOperator,
+		Provider:      p.Provider,
+		Price:         p.Price,
+	}
+}
+
+type consentDTO struct {
+	ID        int64     `json:"id"`
+	Channel   string    `json:"channel"`
+	Status    string    `json:"status"`
+	Source    string    `json:"source"`
+	Proof     string    `json:"proof"`
+	CreatedAt time.Time `json:"createdAt"`
+}
+
+func consentToDTO(c *models.Consent) *consentDTO {
+	return &consentDTO{
+		ID:        c.ID,
+		Channel:   c.Channel,
+		Status:    string(c.Status),
+		Source:    c.Source,
+		Proof:     c.Proof,
+		CreatedAt: c.CreatedAt,
+	}
+}
+
+type consentRequestDTO struct {
+	Channel string `json:"channel"`
+}
+
+type tagsDTO struct {
+	Tags []string `json:"tags"`
+}
+
+type tagsUpdateDTO struct {
+	ClientIDs []int64  `json:"clientIDs"`
+	Add       []string `json:"add"`
+	Remove    []string `json:"remove"`
 }
 
 type mailingUpdateDTO struct {
-	Text      string    `json:"text"`
-	Filter    filterDTO `json:"filter"`
-	StartTime time.Time `json:"startTime"`
-	EndTime   time.Time `json:"endTime"`
+	Text            string            `json:"text"`
+	Filter          *filterDTO        `json:"filter"`
+	StartTime       time.Time         `json:"startTime"`
+	EndTime         time.Time         `json:"endTime"`
+	TTL             string            `json:"ttl"`
+	SegmentID       *int64            `json:"segmentID"`
+	TemplateID      *int64            `json:"templateID"`
+	TemplateVersion int               `json:"templateVersion"`
+	Variants        map[string]string `json:"variants"`
+	MaxSegments     *int              `json:"maxSegments"`
+	RequireConsent  *bool             `json:"requireConsent"`
+	HoldoutPercent  *int              `json:"holdoutPercent"`
+	ABTest          *abTestDTO        `json:"abTest"`
+	SamplePercent   *int              `json:"samplePercent"`
+	MaxRecipients   *int              `json:"maxRecipients"`
+	SampleSeed      *int64            `json:"sampleSeed"`
+	Budget          *int64            `json:"budget"`
 }
 
+// filterDTO is either a filter expression or a flat filter, kept for compatibility.
+// Flat fields are ignored if operator is set.
 type filterDTO struct {
-	PhoneOperator int    `json:"phoneOperator"`
-	Tag           string `json:"tag"`
-	Timezone      string `json:"timezone"`
+	PhoneOperator int    `json:"phoneOperator,omitempty"`
+	Tag           string `json:"tag,omitempty"`
+	Timezone      string `json:"timezone,omitempty"`
+
+	Op     string       `json:"op,omitempty"`
+	Field  string       `json:"field,omitempty"`
+	Value  any          `json:"value,omitempty"`
+	Values []any        `json:"values,omitempty"`
+	From   any          `json:"from,omitempty"`
+	To     any          `json:"to,omitempty"`
+	Args   []*filterDTO `json:"args,omitempty"`
+}
+
+func filterToDTO(f *models.Filter) *filterDTO {
+	if f == nil {
+		return nil
+	}
+	args := []*filterDTO{}
+	for _, arg := range f.Args {
+		args = append(args, filterToDTO(arg))
+	}
+	return &filterDTO{
+		Op:     string(f.Op),
+		Field:  f.Field,
+		Value:  f.Value,
+		Values: f.Values,
+		From:   f.From,
+		To:     f.To,
+		Args:   args,
+	}
+}
+
+// filterFromDTO returns nil if filter is empty, which means "any client".
+func filterFromDTO(f *filterDTO) *models.Filter {
+	if f == nil {
+		return nil
+	}
+	if f.Op == "" {
+		if f.PhoneOperator == 0 && f.Tag == "" && f.Timezone == "" {
+			return nil
+		}
+		return models.NewFlatFilter(f.PhoneOperator, f.Tag, f.Timezone)
+	}
+	args := []*models.Filter{}
+	for _, arg := range f.Args {
+		args = append(args, filterFromDTO(arg))
+	}
+	return &models.Filter{
+		Op:     models.FilterOp(f.Op),
+		Field:  f.Field,
+		Value:  f.Value,
+		Values: f.Values,
+		From:   f.From,
+		To:     f.To,
+		Args:   args,
+	}
+}
+
+type segmentDTO struct {
+	ID     int64      `json:"id"`
+	Name   string     `json:"name"`
+	Filter *filterDTO `json:"filter"`
+	Size   int        `json:"size"`
+}
+
+func segmentToDTO(s *models.Segment, size int) *segmentDTO {
+	return &segmentDTO{
+		ID:     s.ID,
+		Name:   s.Name,
+		Filter: filterToDTO(s.Filter),
+		Size:   size,
+	}
+}
+
+type segmentUpdateDTO struct {
+	Name   string     `json:"name"`
+	Filter *filterDTO `json:"filter"`
+}
+
+type templateDTO struct {
+	ID        int64                 `json:"id"`
+	Name      string                `json:"name"`
+	Version   int                   `json:"version"`
+	Text      string                `json:"text"`
+	UpdatedAt time.Time             `json:"updatedAt"`
+	Versions  []*templateVersionDTO `json:"versions,omitempty"`
+}
+
+func templateToDTO(t *models.Template) *templateDTO {
+	return &templateDTO{
+		ID:        t.ID,
+		Name:      t.Name,
+		Version:   t.Version,
+		Text:      t.Text,
+		UpdatedAt: t.UpdatedAt,
+	}
+}
+
+type templateVersionDTO struct {
+	Version   int       `json:"version"`
+	Text      string    `json:"text"`
+	CreatedAt time.Time `json:"createdAt"`
+}
+
+func templateVersionToDTO(v *models.TemplateVersion) *templateVersionDTO {
+	return &templateVersionDTO{
+		Version:   v.Version,
+		Text:      v.Text,
+		CreatedAt: v.CreatedAt,
+	}
 }
 
 var mailingStatus map[models.MailingStatus]string = map[models.MailingStatus]string{
 	0: "pending", 1: "executing", 2: "done", 3: "canceled", 4: "failed", 5: "invalid", 6: "not a mailing",
+	7: "budget exceeded", 8: "testing",
 }
 
 type mailingDTO struct {
-	ID        uuid.UUID  `json:"id"`
-	Text      string     `json:"text"`
-	Filter    *filterDTO `json:"filter"`
-	StartTime string     `json:"startTime"`
-	EndTime   string     `json:"endTime"`
-	Status    string     `json:"status"`
+	ID              uuid.UUID         `json:"id"`
+	Text            string            `json:"text"`
+	Filter          *filterDTO        `json:"filter"`
+	StartTime       string            `json:"startTime"`
+	EndTime         string            `json:"endTime"`
+	Status          string            `json:"status"`
+	TTL             string            `json:"ttl"`
+	SegmentID       *int64            `json:"segmentID"`
+	AudienceMode    string            `json:"audienceMode"`
+	TemplateID      *int64            `json:"templateID"`
+	TemplateVersion int               `json:"templateVersion"`
+	Variants        map[string]string `json:"variants"`
+	MaxSegments     int               `json:"maxSegments"`
+	RequireConsent  bool              `json:"requireConsent"`
+	HoldoutPercent  int               `json:"holdoutPercent"`
+	ABTest          *abTestDTO        `json:"abTest"`
+	SamplePercent   int               `json:"samplePercent"`
+	MaxRecipients   int               `json:"maxRecipients"`
+	SampleSeed      int64             `json:"sampleSeed"`
+	Budget          int64             `json:"budget"`
 }
 
 func mailingToDTO(m *models.Mailing) *mailingDTO {
 	return &mailingDTO{
-		ID:   m.ID,
-		Text: m.Text,
-		Filter: &filterDTO{
-			PhoneOperator: m.Filter.PhoneOperator,
-			Tag:           m.Filter.Tag,
-			Timezone:      m.Filter.Timezone,
-		},
-		StartTime: m.StartTime.String(),
-		EndTime:   m.EndTime.String(),
-		Status:    mailingStatus[m.Status],
+		ID:              m.ID,
+		Text:            m.Text,
+		Filter:          filterToDTO(m.Filter),
+		StartTime:       m.StartTime.String(),
+		EndTime:         m.EndTime.String(),
+		Status:          mailingStatus[m.Status],
+		TTL:             ttlToDTO(m.TTL),
+		SegmentID:       m.SegmentID,
+		AudienceMode:    string(m.AudienceMode),
+		TemplateID:      m.TemplateID,
+		TemplateVersion: m.TemplateVersion,
+		Variants:        m.Variants,
+		MaxSegments:     m.MaxSegments,
+		RequireConsent:  m.RequireConsent,
+		HoldoutPercent:  m.HoldoutPercent,
+		ABTest:          abTestToDTO(m.ABTest),
+		SamplePercent:   m.SamplePercent,
+		MaxRecipients:   m.MaxRecipients,
+		SampleSeed:      m.SampleSeed,
+		Budget:          m.Budget,
+	}
+}
+
+type abTestDTO struct {
+	Variants    []string `json:"variants"`
+	CellPercent int      `json:"cellPercent"`
+	Wait        string   `json:"wait"`
+	Metric      string   `json:"metric"`
+	Winner      int      `json:"winner"`
+	// RolloutAt is set once test is sent.
+	RolloutAt *time.Time `json:"rolloutAt,omitempty"`
+}
+
+func abTestToDTO(t *models.ABTest) *abTestDTO {
+	if t == nil {
+		return nil
+	}
+	dto := &abTestDTO{
+		Variants:    t.Variants,
+		CellPercent: t.CellPercent,
+		Wait:        t.Wait.String(),
+		Metric:      string(t.Metric),
+		Winner:      t.Winner,
+	}
+	if !t.RolloutAt.IsZero() {
+		dto.RolloutAt = &t.RolloutAt
+	}
+	return dto
+}
+
+func ttlToDTO(ttl time.Duration) string {
+	if ttl == 0 {
+		return ""
+	}
+	return ttl.String()
+}
+
+type mailingPreviewDTO struct {
+	Matches           int                  `json:"matches"`
+	Suppressed        int                  `json:"suppressed"`
+	SampledOut        int                  `json:"sampledOut"`
+	ByPhoneOperator   map[int]int          `json:"byPhoneOperator"`
+	ByTimezone        map[string]int       `json:"byTimezone"`
+	ByTag             map[string]int       `json:"byTag"`
+	ByLocale          map[string]int       `json:"byLocale"`
+	Samples           []*previewMessageDTO `json:"samples"`
+	RateLimit         int                  `json:"rateLimit"`
+	EstimatedDuration string               `json:"estimatedDuration"`
+	FitsWindow        bool                 `json:"fitsWindow"`
+}
+
+type previewMessageDTO struct {
+	ClientID    int64  `json:"clientID"`
+	PhoneNumber int64  `json:"phoneNumber"`
+	Text        string `json:"text"`
+	Locale      string `json:"locale"`
+	Segments    int    `json:"segments"`
+	Error       string `json:"error,omitempty"`
+}
+
+func mailingPreviewToDTO(p *models.MailingPreview) *mailingPreviewDTO {
+	samples := []*previewMessageDTO{}
+	for _, msg := range p.Samples {
+		samples = append(samples, &previewMessageDTO{
+			ClientID:    msg.ClientID,
+			PhoneNumber: msg.PhoneNumber,
+			Text:        msg.Text,
+			Locale:      msg.Locale,
+			Segments:    msg.Segments,
+			Error:       msg.Error,
+		})
+	}
+	return &mailingPreviewDTO{
+		Matches:           p.Matches,
+		Suppressed:        p.Suppressed,
+		SampledOut:        p.SampledOut,
+		ByPhoneOperator:   p.ByPhoneOperator,
+		ByTimezone:        p.ByTimezone,
+		ByTag:             p.ByTag,
+		ByLocale:          p.ByLocale,
+		Samples:           samples,
+		RateLimit:         p.RateLimit,
+		EstimatedDuration: p.EstimatedDuration.String(),
+		FitsWindow:        p.FitsWindow,
 	}
 }
 
 type mailingStatisticDTO struct {
 	ID            uuid.UUID `json:"id"`
+	Run           int       `json:"run"`
 	Matches       int       `json:"matches"`
 	Sent          int       `json:"sent"`
 	Fails         int       `json:"fails"`
+	Expired       int       `json:"expired"`
+	Suppressed    int       `json:"suppressed"`
+	Skipped       int       `json:"skipped"`
+	HeldOut       int       `json:"heldOut"`
+	Cost          int64     `json:"cost"`
 	StartTime     time.Time `json:"startTime"`
 	TimeExecuting string    `json:"timeExecuting"`
 }
@@ -87,38 +416,94 @@ type mailingStatisticDTO struct {
 func mailingStatisticToDTO(s *models.MailingStats) *mailingStatisticDTO {
 	return &mailingStatisticDTO{
 		ID:            s.ID,
+		Run:           s.Run,
 		Matches:       s.Matches,
 		Sent:          s.Sent,
 		Fails:         s.Fails,
+		Expired:       s.Expired,
+		Suppressed:    s.Suppressed,
+		Skipped:       s.Skipped,
+		HeldOut:       s.HeldOut,
+		Cost:          s.Cost,
 		StartTime:     s.StartTime,
 		TimeExecuting: s.TimeExecuting.String(),
 	}
 }
 
-var messageStatus map[models.SendStatus]string = map[models.SendStatus]string{0: "pending", 1: "success", 2: "failed"}
+var messageStatus map[models.SendStatus]string = map[models.SendStatus]string{0: "pending", 1: "success", 2: "failed", 3: "expired", 4: "skipped"}
 
 type messageDTO struct {
-	ID        int64     `json:"id"`
-	TimeStamp time.Time `json:"timeStamp"`
-	MailingID uuid.UUID `json:"mailingID"`
-	ClientID  int64     `json:"clientID"`
-	Status    string    `json:"status"`
+	ID         int64      `json:"id"`
+	TimeStamp  time.Time  `json:"timeStamp"`
+	MailingID  uuid.UUID  `json:"mailingID"`
+	ClientID   int64      `json:"clientID"`
+	Status     string     `json:"status"`
+	LastError  string     `json:"lastError,omitempty"`
+	SkipReason string     `json:"skipReason,omitempty"`
+	Run        int        `json:"run"`
+	ExpiresAt  *time.Time `json:"expiresAt,omitempty"`
+	Text       string     `json:"text"`
+	Variant    int        `json:"variant"`
+	Rollout    bool       `json:"rollout"`
+	Locale     string     `json:"locale"`
+	Segments   int        `json:"segments"`
+	Clicks     int        `json:"clicks"`
+	Cost       int64      `json:"cost"`
 }
 
 func messageToDTO(m *models.Message) *messageDTO {
 	return &messageDTO{
-		ID:        m.ID,
-		TimeStamp: m.TimeStamp,
-		MailingID: m.MailingID,
-		ClientID:  m.ClientID,
-		Status:    messageStatus[m.Status],
+		ID:         m.ID,
+		TimeStamp:  m.TimeStamp,
+		MailingID:  m.MailingID,
+		ClientID:   m.ClientID,
+		Status:     messageStatus[m.Status],
+		LastError:  m.LastError,
+		SkipReason: m.SkipReason,
+		Run:        m.Run,
+		ExpiresAt:  m.ExpiresAt,
+		Text:       m.Text,
+		Variant:    m.Variant,
+		Rollout:    m.Rollout,
+		Locale:     m.Locale,
+		Segments:   m.Segments,
+		Clicks:     m.Clicks,
+		Cost:       m.Cost,
 	}
 }
 
 type detailedMailingStatsDTO struct {
-	CommonStats *mailingStatisticDTO `json:"commonStatistic"`
-	Attributes  *mailingDTO          `json:"attributes"`
-	Messages    []*messageDTO        `json:"messages"`
+	CommonStats *mailingStatisticDTO   `json:"commonStatistic"`
+	FollowUps   []*mailingStatisticDTO `json:"followUps"`
+	Attributes  *mailingDTO            `json:"attributes"`
+	Messages    []*messageDTO          `json:"messages"`
+	Locales     []*localeStatsDTO      `json:"locales"`
+	Clicks      *clickStatsDTO         `json:"clicks"`
+	Variants    []*variantStatsDTO     `json:"variants"`
+}
+
+type variantStatsDTO struct {
+	Variant      int     `json:"variant"`
+	Messages     int     `json:"messages"`
+	Success      int     `json:"success"`
+	Fails        int     `json:"fails"`
+	Clicked      int     `json:"clicked"`
+	DeliveryRate float64 `json:"deliveryRate"`
+	ClickRate    float64 `json:"clickRate"`
+}
+
+type clickStatsDTO struct {
+	Delivered        int     `json:"delivered"`
+	Clicked          int     `json:"clicked"`
+	Clicks           int     `json:"clicks"`
+	ClickThroughRate float64 `json:"clickThroughRate"`
+}
+
+type localeStatsDTO struct {
+	Locale   string `json:"locale"`
+	Messages int    `json:"messages"`
+	Success  int    `json:"success"`
+	Fails    int    `json:"fails"`
 }
 
 func detailedStatisticToDTO(d *models.DetailedMailingStats) *detailedMailingStatsDTO {
@@ -126,9 +511,137 @@ func detailedStatisticToDTO(d *models.DetailedMailingStats) *detailedMailingStat
 	for _, msg := range d.Messages {
 		messages = append(messages, messageToDTO(&msg))
 	}
+	followUps := []*mailingStatisticDTO{}
+	for _, stats := range d.FollowUps {
+		followUps = append(followUps, mailingStatisticToDTO(&stats))
+	}
+	locales := []*localeStatsDTO{}
+	for _, stats := range d.Locales {
+		locales = append(locales, &localeStatsDTO{
+			Locale:   stats.Locale,
+			Messages: stats.Messages,
+			Success:  stats.Success,
+			Fails:    stats.Fails,
+		})
+	}
+	variants := []*variantStatsDTO{}
+	for _, stats := range d.Variants {
+		variants = append(variants, &variantStatsDTO{
+			Variant:      stats.Variant,
+			Messages:     stats.Messages,
+			Success:      stats.Success,
+			Fails:        stats.Fails,
+			Clicked:      stats.Clicked,
+			DeliveryRate: stats.Rate(models.ABTestMetricDelivery),
+			ClickRate:    stats.Rate(models.ABTestMetricClick),
+		})
+	}
 	return &detailedMailingStatsDTO{
 		CommonStats: mailingStatisticToDTO(&d.CommonStats),
+		FollowUps:   followUps,
 		Attributes:  mailingToDTO(&d.Attributes),
 		Messages:    messages,
+		Locales:     locales,
+		Clicks: &clickStatsDTO{
+			Delivered:        d.Clicks.Delivered,
+			Clicked:          d.Clicks.Clicked,
+			Clicks:           d.Clicks.Clicks,
+			ClickThroughRate: d.Clicks.ClickThroughRate(),
+		},
+		Variants: variants,
+	}
+}
+
+type deadLetterReplayDTO struct {
+	MessageIDs []int64    `json:"messageIDs"`
+	MailingID  *uuid.UUID `json:"mailingID"`
+	EndTime    string     `json:"endTime"`
+}
+
+type inboundMessageDTO struct {
+	ID          int64     `json:"id"`
+	PhoneNumber int64     `json:"phoneNumber"`
+	ClientID    *int64    `json:"clientID"`
+	ReplyTo     *int64    `json:"replyTo"`
+	Text        string    `json:"text"`
+	ReceivedAt  time.Time `json:"receivedAt"`
+	Stop        bool      `json:"stop"`
+}
+
+type conversationEntryDTO struct {
+	Direction string     `json:"direction"`
+	ID        int64      `json:"id"`
+	Text      string     `json:"text"`
+	Time      time.Time  `json:"time"`
+	Status    string     `json:"status,omitempty"`
+	MailingID *uuid.UUID `json:"mailingID,omitempty"`
+	ReplyTo   *int64     `json:"replyTo,omitempty"`
+}
+
+func conversationEntryToDTO(e *models.ConversationEntry) *conversationEntryDTO {
+	entry := &conversationEntryDTO{
+		Direction: string(e.Direction),
+		ID:        e.ID,
+		Text:      e.Text,
+		Time:      e.Time,
+		MailingID: e.MailingID,
+		ReplyTo:   e.ReplyTo,
+	}
+	if e.Status != nil {
+		entry.Status = messageStatus[*e.Status]
+	}
+	return entry
+}
+
+type textDTO struct {
+	Text string `json:"text"`
+}
+
+type textAnalysisDTO struct {
+	Encoding          string   `json:"encoding"`
+	Length            int      `json:"length"`
+	Segments          int      `json:"segments"`
+	UnicodeCharacters []string `json:"unicodeCharacters"`
+}
+
+func textAnalysisToDTO(a *models.TextAnalysis) *textAnalysisDTO {
+	return &textAnalysisDTO{
+		Encoding:          string(a.Encoding),
+		Length:            a.Length,
+		Segments:          a.Segments,
+		UnicodeCharacters: a.UnicodeCharacters,
+	}
+}
+
+type sendMessageDTO struct {
+	Text            string `json:"text"`
+	TTL             string `json:"ttl"`
+	TemplateID      *int64 `json:"templateID"`
+	TemplateVersion int    `json:"templateVersion"`
+}
+
+type resendFailedDTO struct {
+	EndTime string `json:"endTime"`
+}
+
+type resendStartedDTO struct {
+	Run int `json:"run"`
+}
+
+type replayResultDTO struct {
+	Replayed  int `json:"replayed"`
+	Succeeded int `json:"succeeded"`
+	Failed    int `json:"failed"`
+	Expired   int `json:"expired"`
+	Skipped   int `json:"skipped"`
+}
+
+func replayResultToDTO(r *models.ReplayResult) *replayResultDTO {
+	return &replayResultDTO{
+		Replayed:  r.Replayed,
+		Succeeded: r.Succeeded,
+		Failed:    r.Failed,
+		Expired:   r.Expired,
+		Skipped:   r.Skipped,
 	}
 }
@@ -2,10 +2,12 @@ package httpcontroller
 
 import (
 	"fmt"
+	"io"
 	"net/http"
 	"os"
 	"strconv"
 	"time"
+	"unicode/utf8"
 
 	"github.com/gin-gonic/gin"
 	"github.com/google/uuid"
@@ -51,15 +53,91 @@ func (h *HTTPController) Start() error {
 	h.router.PUT("/clients/:id", h.updateClient)
 	// Deletes existing client.
 	h.router.DELETE("clients/:id", h.deleteClient)
+	// Adds tags to client.
+	h.router.POST("/clients/:id/tags", h.addClientTags)
+	// Removes tag from client.
+	h.router.DELETE("/clients/:id/tags/:tag", h.removeClientTag)
+	// Retrives messages sent to and received from client.
+	h.router.GET("/clients/:id/conversation", h.getConversation)
+	// Sends reply to client in conversation.
+	h.router.POST("/clients/:id/conversation", h.replyInConversation)
+	// Retrives consent records of client.
+	h.router.GET("/clients/:id/consents", h.getConsents)
+	// Records consent of client, that was granted or revoked outside of service.
+	h.router.POST("/clients/:id/consents", h.saveConsent)
+	// Starts double opt-in of client by sending confirmation request.
+	h.router.POST("/clients/:id/consents/opt-in", h.requestConsent)
+	// Confirms double opt-in by link from confirmation request.
+	h.router.GET("/consent/:token", h.confirmConsent)
+
+	// Retrives definitions of custom client's attributes.
+	h.router.GET("/attributes", h.getAttributes)
+	// Adds or changes definition of custom client's attribute.
+	h.router.POST("/attributes", h.saveAttribute)
+	// Deletes definition of custom client's attribute and it's values.
+	h.router.DELETE("/attributes/:name", h.deleteAttribute)
+
+	// Retrives suppression list.
+	h.router.GET("/suppressions", h.getSuppressions)
+	// Adds client or phone number to suppression list.
+	h.router.POST("/suppressions", h.saveSuppression)
+	// Deletes entry from suppression list, so client may receive messages again.
+	h.router.DELETE("/suppressions/:id", h.deleteSuppression)
+
+	// Retrives price table.
+	h.router.GET("/prices", h.getPrices)
+	// Adds price to price table or changes price of the same phone operator and provider.
+	h.router.POST("/prices", h.savePrice)
+	// Deletes price from price table.
+	h.router.DELETE("/prices/:id", h.deletePrice)
+
+	// Retrives all tags.
+	h.router.GET("/tags", h.getTags)
+	// Adds and removes tags of many clients at once.
+	h.router.POST("/tags/bulk", h.updateTags)
+
+	// Retrives all segments with their sizes.
+	h.router.GET("/segments", h.getSegments)
+	// Adds new segment.
+	h.router.POST("/segments", h.saveSegment)
+	// Retrives segment with it's size.
+	h.router.GET("/segments/:id", h.getSegment)
+	// Changes existing segment.
+	h.router.PUT("/segments/:id", h.updateSegment)
+	// Deletes segment, that is not used by mailings.
+	h.router.DELETE("/segments/:id", h.deleteSegment)
+	// Retrives clients belonging to segment.
+	h.router.GET("/segments/:id/members", h.getSegmentMembers)
+
+	// Retrives all templates with their latest versions.
+	h.router.GET("/templates", h.getTemplates)
+	// Adds new template.
+	h.router.POST("/templates", h.saveTemplate)
+	// Retrives template with all it's versions.
+	h.router.GET("/templates/:id", h.getTemplate)
+	// Adds new version of template.
+	h.router.POST("/templates/:id/versions", h.addTemplateVersion)
+	// Retrives given version of template.
+	h.router.GET("/templates/:id/versions/:version", h.getTemplateVersion)
+	// Deletes template with all it's versions.
+	h.router.DELETE("/templates/:id", h.deleteTemplate)
 
 	// Retrives all mailings.
 	h.router.GET("/mailings", h.getMailings)
 	// Adds new mailing.
 	h.router.POST("/mailings", h.saveMailing)
+	// Previews audience and messages of mailing without saving it.
+	h.router.POST("/mailings/preview", h.previewMailing)
 	// Changes existing mailing.
 	h.router.PUT("/mailings/:id", h.updateMailing)
 	// Deletes existing mailing.
 	h.router.DELETE("/mailings/:id", h.deleteMailing)
+	// Retrives audience snapshot of mailing.
+	h.router.GET("/mailings/:id/audience", h.getMailingAudience)
+	// Retrives holdout group of mailing.
+	h.router.GET("/mailings/:id/holdout", h.getMailingHoldout)
+	// Starts follow-up run of finished mailing in background, resending failed messages.
+	h.router.POST("/mailings/:id/resend-failed", h.resendFailed)
 
 	// Retrives common statistic for all mailings.
 	h.router.GET("/mailings/statistic", h.commonStatistic)
@@ -67,6 +145,17 @@ func (h *HTTPController) Start() error {
 	h.router.GET("/mailings/statistic/:id", h.detailedStatistic)
 	// Sends message to user.
 	h.router.POST("/send/:id", h.sendMessage)
+	// Receives message from client's phone, e.g. reply to mailing, webhook for SMS provider.
+	h.router.POST("/inbound", h.receiveMessage)
+	// Analyzes encoding and SMS segments of text.
+	h.router.POST("/texts/analyze", h.analyzeText)
+	// Redirects from short link to it's URL, counting the click.
+	h.router.GET("/l/:code", h.followLink)
+
+	// Retrives messages, that exhausted all send retries.
+	h.router.GET("/dead-letters", h.getDeadLetters)
+	// Resends selected failed messages.
+	h.router.POST("/dead-letters/replay", h.replayDeadLetters)
 	// Opens API reference.
 	h.router.GET("/docs/*any", gin.WrapH(swag.New("Mailing Service", "/static/openapi.json", "/docs/")))
 
@@ -104,11 +193,36 @@ func (h *HTTPController) saveClient(c *gin.Context) {
 		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
 		return
 	}
+	err = validateTags(client.Tags)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	locale, err := models.NormalizeLocale(client.Locale)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if client.Attributes == nil {
+		client.Attributes = map[string]any{}
+	}
+	attributes, err := h.service.Storage.GetAttributeDefinitions(c.Request.Context())
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	err = models.ValidateAttributes(client.Attributes, attributes, false)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
 	err = h.service.Storage.SaveClient(c.Request.Context(), &models.Client{
 		PhoneNumber:   client.PhoneNumber,
 		PhoneOperator: client.PhoneOperator,
-		Tag:           client.Tag,
+		Tags:          client.Tags,
 		Timezone:      client.Timezone,
+		Attributes:    client.Attributes,
+		Locale:        locale,
 	})
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
@@ -134,11 +248,33 @@ func (h *HTTPController) updateClient(c *gin.Context) {
 		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
 		return
 	}
+	err = validateTags(update.Tags)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	locale, err := models.NormalizeLocale(update.Locale)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	attributes, err := h.service.Storage.GetAttributeDefinitions(c.Request.Context())
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	err = models.ValidateAttributes(update.Attributes, attributes, true)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
 	err = h.service.Storage.UpdateClient(c.Request.Context(), id, &models.ClientUpdate{
 		PhoneNumber:   update.PhoneNumber,
 		PhoneOperator: update.PhoneOperator,
-		Tag:           update.Tag,
+		Tags:          update.Tags,
 		Timezone:      update.Timezone,
+		Attributes:    update.Attributes,
+		Locale:        locale,
 	})
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
@@ -165,212 +301,1638 @@ func (h *HTTPController) deleteClient(c *gin.Context) {
 	}
 }
 
-// getMailings retrives all mailings.
-// Doesn't convert it to DTO, altough should, so fields would be styled in json way and the status would be human readable :)
-func (h *HTTPController) getMailings(c *gin.Context) {
-	mailings, err := h.service.Storage.GetMailings(c.Request.Context())
+// addClientTags adds tags to client.
+func (h *HTTPController) addClientTags(c *gin.Context) {
+	idURL := c.Param("id")
+	if idURL == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "no id specified"})
+		return
+	}
+	id, err := strconv.ParseInt(idURL, 10, 64)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	tags := tagsDTO{}
+	err = c.ShouldBind(&tags)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	err = validateTags(tags.Tags)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	err = h.service.Storage.UpdateTags(c.Request.Context(), &models.TagsUpdate{
+		ClientIDs: []int64{id},
+		Add:       tags.Tags,
+	})
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
 		return
 	}
-	mailingsDTO := []*mailingDTO{}
-	for _, mailing := range mailings {
-		mailingsDTO = append(mailingsDTO, mailingToDTO(mailing))
+}
+
+// removeClientTag removes tag from client.
+func (h *HTTPController) removeClientTag(c *gin.Context) {
+	idURL := c.Param("id")
+	if idURL == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "no id specified"})
+		return
+	}
+	id, err := strconv.ParseInt(idURL, 10, 64)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	err = h.service.Storage.UpdateTags(c.Request.Context(), &models.TagsUpdate{
+		ClientIDs: []int64{id},
+		Remove:    []string{c.Param("tag")},
+	})
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
 	}
-	c.JSONP(http.StatusOK, mailingsDTO)
 }
 
-// saveMailing adds new mailing.
-func (h *HTTPController) saveMailing(c *gin.Context) {
-	msk, err := time.LoadLocation("Europe/Moscow")
+// getAttributes retrives definitions of custom client's attributes.
+func (h *HTTPController) getAttributes(c *gin.Context) {
+	attributes, err := h.service.Storage.GetAttributeDefinitions(c.Request.Context())
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
 		return
 	}
-	mailing := mailingDTO{}
-	err = c.ShouldBind(&mailing)
+	attributesDTO := []*attributeDefinitionDTO{}
+	for _, a := range attributes {
+		attributesDTO = append(attributesDTO, attributeDefinitionToDTO(a))
+	}
+	c.JSONP(http.StatusOK, attributesDTO)
+}
+
+// saveAttribute adds or changes definition of custom client's attribute.
+func (h *HTTPController) saveAttribute(c *gin.Context) {
+	attribute := attributeDefinitionDTO{}
+	err := c.ShouldBind(&attribute)
 	if err != nil {
 		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
 		return
 	}
-	var start, end time.Time
-	if mailing.StartTime == "" {
-		start = time.Now()
-	} else {
-		start, err = time.ParseInLocation("02-01-2006 15:04", mailing.StartTime, msk)
-		if err != nil {
-			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
-			return
-		}
+	definition := &models.AttributeDefinition{
+		Name:          attribute.Name,
+		Type:          models.AttributeType(attribute.Type),
+		Required:      attribute.Required,
+		AllowedValues: attribute.AllowedValues,
 	}
-	if mailing.EndTime == "" {
-		end = time.Now().Add(time.Hour)
-	} else {
-		end, err = time.ParseInLocation("02-01-2006 15:04", mailing.EndTime, msk)
-		if err != nil {
-			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
-			return
-		}
+	err = definition.Validate()
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	err = h.service.Storage.SaveAttributeDefinition(c.Request.Context(), definition)
+	if errors.Is(err, models.ErrAttributeTypeConflict) {
+		c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+		return
 	}
-	uuid := uuid.New()
-	err = h.service.Storage.SaveMailing(c.Request.Context(), &models.Mailing{
-		ID:   uuid,
-		Text: mailing.Text,
-		Filter: &models.Filter{
-			PhoneOperator: mailing.Filter.PhoneOperator,
-			Tag:           mailing.Filter.Tag,
-			Timezone:      mailing.Filter.Timezone,
-		},
-		StartTime: start.UTC(),
-		EndTime:   end.UTC(),
-		Status:    models.MailingStatusPending,
-	})
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
 		return
 	}
 }
 
-// updateMailing changes existing mailing.
-func (h *HTTPController) updateMailing(c *gin.Context) {
-	idURL := c.Param("id")
-	if idURL == "" {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "no id specified"})
+// deleteAttribute deletes definition of custom client's attribute and it's values.
+func (h *HTTPController) deleteAttribute(c *gin.Context) {
+	name := c.Param("name")
+	if name == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "no name specified"})
+		return
+	}
+	err := h.service.DeleteAttribute(c.Request.Context(), name)
+	if errors.Is(err, models.ErrAttributeInUse) {
+		c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
 		return
 	}
-	id, err := uuid.Parse(idURL)
 	if err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
 		return
 	}
-	update := mailingUpdateDTO{}
-	err = c.ShouldBind(&update)
+}
+
+// getConversation retrives the latest messages sent to and received from client in chronological order.
+func (h *HTTPController) getConversation(c *gin.Context) {
+	id, err := strconv.ParseInt(c.Param("id"), 10, 64)
 	if err != nil {
 		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
 		return
 	}
-	err = h.service.Storage.UpdateMailing(c.Request.Context(), id, &models.MailingUpdate{
-		Text: update.Text,
-		Filter: &models.Filter{
-			PhoneOperator: update.Filter.PhoneOperator,
-			Tag:           update.Filter.Tag,
-			Timezone:      update.Filter.Timezone,
-		},
-		StartTime: update.StartTime,
-		EndTime:   update.EndTime,
-	})
+	limit, err := strconv.Atoi(c.DefaultQuery("limit", "100"))
+	if err != nil || limit < 1 || limit > 1000 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "limit must be from 1 to 1000"})
+		return
+	}
+	offset, err := strconv.Atoi(c.DefaultQuery("offset", "0"))
+	if err != nil || offset < 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "offset must not be negative"})
+		return
+	}
+	_, err = h.service.Storage.GetClientByID(c.Request.Context(), id)
+	if errors.Is(err, models.ErrClientNotFound) {
+		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	entries, err := h.service.Storage.GetConversation(c.Request.Context(), id, limit, offset)
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
 		return
 	}
+	entriesDTO := []*conversationEntryDTO{}
+	for _, e := range entries {
+		entriesDTO = append(entriesDTO, conversationEntryToDTO(e))
+	}
+	c.JSONP(http.StatusOK, entriesDTO)
 }
 
-// deleteMailing deletes existing mailing.
-func (h *HTTPController) deleteMailing(c *gin.Context) {
-	idURL := c.Param("id")
-	if idURL == "" {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "no id specified"})
+// replyInConversation sends agent's reply to client.
+func (h *HTTPController) replyInConversation(c *gin.Context) {
+	id, err := strconv.ParseInt(c.Param("id"), 10, 64)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
 		return
 	}
-	id, err := uuid.Parse(idURL)
+	reply := textDTO{}
+	err = c.ShouldBind(&reply)
 	if err != nil {
 		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
 		return
 	}
-	err = h.service.Storage.DeleteMailing(c.Request.Context(), id)
+	if reply.Text == "" || utf8.RuneCountInString(reply.Text) > 5000 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "text must be from 1 to 5000 characters long"})
+		return
+	}
+	_, err = h.service.Reply(c.Request.Context(), id, reply.Text)
 	if err != nil {
+		if errors.Is(err, models.ErrClientNotFound) {
+			c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+			return
+		}
+		if errors.Is(err, models.ErrClientSuppressed) {
+			c.JSON(http.StatusForbidden, gin.H{"error": err.Error()})
+			return
+		}
 		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
 		return
 	}
 }
 
-// commonStatistic retrives common statistic for all mailings.
-func (h *HTTPController) commonStatistic(c *gin.Context) {
-	statistic, err := h.service.Storage.CommonStatistic(c.Request.Context())
+// getConsents retrives consent records of client, the newest first.
+func (h *HTTPController) getConsents(c *gin.Context) {
+	id, err := strconv.ParseInt(c.Param("id"), 10, 64)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	_, err = h.service.Storage.GetClientByID(c.Request.Context(), id)
+	if errors.Is(err, models.ErrClientNotFound) {
+		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+		return
+	}
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
 		return
 	}
-	statisticDTO := []*mailingStatisticDTO{}
-	for _, stat := range statistic {
-		statisticDTO = append(statisticDTO, mailingStatisticToDTO(stat))
+	consents, err := h.service.Storage.GetConsents(c.Request.Context(), id)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
 	}
-	c.JSONP(http.StatusOK, statisticDTO)
+	consentsDTO := []*consentDTO{}
+	for _, consent := range consents {
+		consentsDTO = append(consentsDTO, consentToDTO(consent))
+	}
+	c.JSONP(http.StatusOK, consentsDTO)
 }
 
-// detailedStatistic retrives detailed statistic for given mailing.
-func (h *HTTPController) detailedStatistic(c *gin.Context) {
-	idURL := c.Param("id")
-	if idURL == "" {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "no id specified"})
+// saveConsent records consent granted or revoked by client, e.g. in signup form.
+func (h *HTTPController) saveConsent(c *gin.Context) {
+	id, err := strconv.ParseInt(c.Param("id"), 10, 64)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
 		return
 	}
-	id, err := uuid.Parse(idURL)
+	consent := consentDTO{}
+	err = c.ShouldBind(&consent)
 	if err != nil {
 		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
 		return
 	}
-	statistic, err := h.service.Storage.DetailedStatistic(c.Request.Context(), id)
+	if consent.Channel == "" {
+		consent.Channel = models.ConsentChannelSMS
+	}
+	if consent.Channel != models.ConsentChannelSMS {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "unknown channel " + consent.Channel})
+		return
+	}
+	status := models.ConsentStatus(consent.Status)
+	if status != models.ConsentStatusGranted && status != models.ConsentStatusRevoked {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "status must be granted or revoked"})
+		return
+	}
+	if consent.Source == "" || utf8.RuneCountInString(consent.Source) > 100 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "source must be from 1 to 100 characters long"})
+		return
+	}
+	if utf8.RuneCountInString(consent.Proof) > 1000 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "proof must be at most 1000 characters long"})
+		return
+	}
+	err = h.service.Storage.SaveConsent(c.Request.Context(), &models.Consent{
+		ClientID: id,
+		Channel:  consent.Channel,
+		Status:   status,
+		Source:   consent.Source,
+		Proof:    consent.Proof,
+	})
+	if errors.Is(err, models.ErrClientNotFound) {
+		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+		return
+	}
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
 		return
 	}
-	statisticDTO := detailedStatisticToDTO(statistic)
-	c.JSONP(http.StatusOK, statisticDTO)
 }
 
-// Configures active mailing.
-// Sends message to user.
-func (h *HTTPController) sendMessage(c *gin.Context) {
-	l := zap.L()
-	idURL := c.Param("id")
-	if idURL == "" {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "no id specified"})
+// requestConsent sends double opt-in request to client.
+func (h *HTTPController) requestConsent(c *gin.Context) {
+	id, err := strconv.ParseInt(c.Param("id"), 10, 64)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
 		return
 	}
-	id, err := strconv.ParseInt(idURL, 10, 64)
-	if err != nil {
+	request := consentRequestDTO{}
+	err = c.ShouldBind(&request)
+	if err != nil && !errors.Is(err, io.EOF) {
 		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
 		return
 	}
-	msg := &models.Message{
-		TimeStamp: time.Now(),
-		Status:    models.SendStatusPending,
-		MailingID: uuid.Nil,
-		ClientID:  id,
+	if request.Channel == "" {
+		request.Channel = models.ConsentChannelSMS
+	}
+	if request.Channel != models.ConsentChannelSMS {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "unknown channel " + request.Channel})
+		return
+	}
+	_, err = h.service.RequestConsent(c.Request.Context(), id, request.Channel)
+	if err != nil {
+		if errors.Is(err, models.ErrClientNotFound) {
+			c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+			return
+		}
+		if errors.Is(err, models.ErrClientSuppressed) {
+			c.JSON(http.StatusForbidden, gin.H{"error": err.Error()})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+}
+
+// confirmConsent confirms double opt-in by token from confirmation link.
+func (h *HTTPController) confirmConsent(c *gin.Context) {
+	proof := fmt.Sprintf("link clicked from %s, %s", c.ClientIP(), c.Request.UserAgent())
+	consent, err := h.service.ConfirmConsent(c.Request.Context(), c.Param("token"), proof)
+	if err != nil {
+		if errors.Is(err, models.ErrConsentNotFound) {
+			c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+			return
+		}
+		if errors.Is(err, models.ErrConsentNotPending) {
+			c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+			return
+		}
+		if errors.Is(err, models.ErrConsentExpired) {
+			c.JSON(http.StatusGone, gin.H{"error": err.Error()})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
 	}
-	msg.ID, err = h.service.Storage.SaveMessage(c.Request.Context(), msg)
+	c.JSONP(http.StatusOK, consentToDTO(consent))
+}
+
+// getSuppressions retrives suppression list.
+func (h *HTTPController) getSuppressions(c *gin.Context) {
+	suppressions, err := h.service.Storage.GetSuppressions(c.Request.Context())
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
 		return
 	}
-	type textStruct struct {
-		Text string `json:"text"`
+	suppressionsDTO := []*suppressionDTO{}
+	for _, s := range suppressions {
+		suppressionsDTO = append(suppressionsDTO, suppressionToDTO(s))
 	}
-	var text textStruct
-	err = c.ShouldBind(&text)
+	c.JSONP(http.StatusOK, suppressionsDTO)
+}
+
+// saveSuppression adds client or phone number to suppression list.
+func (h *HTTPController) saveSuppression(c *gin.Context) {
+	suppression := suppressionDTO{}
+	err := c.ShouldBind(&suppression)
 	if err != nil {
 		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
 		return
 	}
-	client, err := h.service.Storage.GetClientByID(c.Request.Context(), id)
+	if suppression.ClientID == nil && suppression.PhoneNumber == nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "either client id or phone number must be specified"})
+		return
+	}
+	if suppression.Source == "" {
+		suppression.Source = models.SuppressionSourceManual
+	}
+	if utf8.RuneCountInString(suppression.Source) > 100 || utf8.RuneCountInString(suppression.Reason) > 500 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "source must be at most 100 and reason at most 500 characters long"})
+		return
+	}
+	err = h.service.Storage.SaveSuppression(c.Request.Context(), &models.Suppression{
+		ClientID:    suppression.ClientID,
+		PhoneNumber: suppression.PhoneNumber,
+		Reason:      suppression.Reason,
+		Source:      suppression.Source,
+	})
+	if errors.Is(err, models.ErrClientNotFound) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if errors.Is(err, models.ErrAlreadySuppressed) {
+		c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+		return
+	}
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
 		return
 	}
-	err = h.service.MessageSender.Send(c.Request.Context(), msg.ID, client.PhoneNumber, text.Text)
+}
+
+// deleteSuppression deletes entry from suppression list.
+func (h *HTTPController) deleteSuppression(c *gin.Context) {
+	id, err := strconv.ParseInt(c.Param("id"), 10, 64)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	err = h.service.Storage.DeleteSuppression(c.Request.Context(), id)
+	if errors.Is(err, models.ErrSuppressionNotFound) {
+		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+		return
+	}
 	if err != nil {
-		nestedErr := h.service.Storage.MarkMessage(c.Request.Context(), msg, models.SendStatusFailed)
-		if nestedErr != nil {
-			l.Error(fmt.Sprintf("FAIL: mark mailing status as failed\nError: %v", nestedErr))
-		}
 		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
 		return
 	}
-	err = h.service.Storage.MarkMessage(c.Request.Context(), msg, models.SendStatusSuccess)
+}
+
+// getPrices retrives price table.
+func (h *HTTPController) getPrices(c *gin.Context) {
+	prices, err := h.service.Storage.GetPrices(c.Request.Context())
 	if err != nil {
-		l.Error(fmt.Sprintf("FAIL: mark mailing status as success\nError: %v", err))
 		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
 		return
 	}
+	pricesDTO := []*priceDTO{}
+	for _, p := range prices {
+		pricesDTO = append(pricesDTO, priceToDTO(p))
+	}
+	c.JSONP(http.StatusOK, pricesDTO)
+}
+
+// savePrice adds price to price table or changes price of the same phone operator and provider.
+func (h *HTTPController) savePrice(c *gin.Context) {
+	price := priceDTO{}
+	err := c.ShouldBind(&price)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if price.PhoneOperator < 0 || price.Price < 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "phone operator and price must not be negative"})
+		return
+	}
+	if utf8.RuneCountInString(price.Provider) > 100 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "provider must be at most 100 characters long"})
+		return
+	}
+	err = h.service.Storage.SavePrice(c.Request.Context(), &models.Price{
+		PhoneOperator: price.PhoneOperator,
+		Provider:      price.Provider,
+		Price:         price.Price,
+	})
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+}
+
+// deletePrice deletes price from price table.
+func (h *HTTPController) deletePrice(c *gin.Context) {
+	id, err := strconv.ParseInt(c.Param("id"), 10, 64)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	err = h.service.Storage.DeletePrice(c.Request.Context(), id)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+}
+
+// getTags retrives all tags.
+func (h *HTTPController) getTags(c *gin.Context) {
+	tags, err := h.service.Storage.GetTags(c.Request.Context())
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSONP(http.StatusOK, tagsDTO{Tags: tags})
+}
+
+// updateTags adds and removes tags of many clients at once.
+func (h *HTTPController) updateTags(c *gin.Context) {
+	update := tagsUpdateDTO{}
+	err := c.ShouldBind(&update)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	err = validateTags(update.Add)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	err = h.service.Storage.UpdateTags(c.Request.Context(), &models.TagsUpdate{
+		ClientIDs: update.ClientIDs,
+		Add:       update.Add,
+		Remove:    update.Remove,
+	})
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+}
+
+// getSegments retrives all segments with their sizes.
+func (h *HTTPController) getSegments(c *gin.Context) {
+	segments, err := h.service.Storage.GetSegments(c.Request.Context())
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	segmentsDTO := []*segmentDTO{}
+	for _, segment := range segments {
+		size, err := h.service.Storage.CountClientsByFilter(c.Request.Context(), segment.Filter)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+		segmentsDTO = append(segmentsDTO, segmentToDTO(segment, size))
+	}
+	c.JSONP(http.StatusOK, segmentsDTO)
+}
+
+// saveSegment adds new segment.
+func (h *HTTPController) saveSegment(c *gin.Context) {
+	segment := segmentUpdateDTO{}
+	err := c.ShouldBind(&segment)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	err = validateSegmentName(segment.Name)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	filter := filterFromDTO(segment.Filter)
+	err = filter.Validate()
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	attributes, err := h.service.Storage.GetAttributeDefinitions(c.Request.Context())
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	err = filter.ValidateAttributes(attributes)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	err = h.service.Storage.SaveSegment(c.Request.Context(), &models.Segment{
+		Name:   segment.Name,
+		Filter: filter,
+	})
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+}
+
+// getSegment retrives segment with it's size.
+func (h *HTTPController) getSegment(c *gin.Context) {
+	id, err := strconv.ParseInt(c.Param("id"), 10, 64)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	segment, err := h.service.Storage.GetSegmentByID(c.Request.Context(), id)
+	if errors.Is(err, models.ErrSegmentNotFound) {
+		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	size, err := h.service.Storage.CountClientsByFilter(c.Request.Context(), segment.Filter)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSONP(http.StatusOK, segmentToDTO(segment, size))
+}
+
+// updateSegment changes existing segment.
+// Pending mailings referencing segment are sent to it's new audience.
+func (h *HTTPController) updateSegment(c *gin.Context) {
+	id, err := strconv.ParseInt(c.Param("id"), 10, 64)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	update := segmentUpdateDTO{}
+	err = c.ShouldBind(&update)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if update.Name != "" {
+		err = validateSegmentName(update.Name)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+	}
+	filter := filterFromDTO(update.Filter)
+	err = filter.Validate()
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	attributes, err := h.service.Storage.GetAttributeDefinitions(c.Request.Context())
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	err = filter.ValidateAttributes(attributes)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	err = h.service.Storage.UpdateSegment(c.Request.Context(), id, &models.SegmentUpdate{
+		Name:   update.Name,
+		Filter: filter,
+	})
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+}
+
+// deleteSegment deletes segment, that is not used by mailings.
+func (h *HTTPController) deleteSegment(c *gin.Context) {
+	id, err := strconv.ParseInt(c.Param("id"), 10, 64)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	err = h.service.Storage.DeleteSegment(c.Request.Context(), id)
+	if errors.Is(err, models.ErrSegmentInUse) {
+		c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+}
+
+// getSegmentMembers retrives clients belonging to segment, paginated with limit and offset query parameters.
+func (h *HTTPController) getSegmentMembers(c *gin.Context) {
+	id, err := strconv.ParseInt(c.Param("id"), 10, 64)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	limit, err := strconv.Atoi(c.DefaultQuery("limit", "100"))
+	if err != nil || limit < 1 || limit > 1000 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "limit must be from 1 to 1000"})
+		return
+	}
+	offset, err := strconv.Atoi(c.DefaultQuery("offset", "0"))
+	if err != nil || offset < 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "offset must not be negative"})
+		return
+	}
+	segment, err := h.service.Storage.GetSegmentByID(c.Request.Context(), id)
+	if errors.Is(err, models.ErrSegmentNotFound) {
+		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	clients, err := h.service.Storage.GetClientsByFilter(c.Request.Context(), segment.Filter, limit, offset)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	clientsDTO := []*clientDTO{}
+	for _, client := range clients {
+		clientsDTO = append(clientsDTO, clientToDTO(client))
+	}
+	c.JSONP(http.StatusOK, clientsDTO)
+}
+
+// getTemplates retrives all templates with their latest versions.
+func (h *HTTPController) getTemplates(c *gin.Context) {
+	templates, err := h.service.Storage.GetTemplates(c.Request.Context())
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	templatesDTO := []*templateDTO{}
+	for _, template := range templates {
+		templatesDTO = append(templatesDTO, templateToDTO(template))
+	}
+	c.JSONP(http.StatusOK, templatesDTO)
+}
+
+// saveTemplate adds new template, it's text becomes the first version.
+func (h *HTTPController) saveTemplate(c *gin.Context) {
+	template := templateDTO{}
+	err := c.ShouldBind(&template)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if template.Name == "" || utf8.RuneCountInString(template.Name) > 100 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "template name must be from 1 to 100 characters long"})
+		return
+	}
+	attributes, err := h.service.Storage.GetAttributeDefinitions(c.Request.Context())
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	err = mailing.ValidateText(template.Text, attributes)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	err = h.service.Storage.SaveTemplate(c.Request.Context(), &models.Template{
+		Name: template.Name,
+		Text: template.Text,
+	})
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+}
+
+// getTemplate retrives template with all it's versions.
+func (h *HTTPController) getTemplate(c *gin.Context) {
+	id, err := strconv.ParseInt(c.Param("id"), 10, 64)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	template, err := h.service.Storage.GetTemplateByID(c.Request.Context(), id)
+	if errors.Is(err, models.ErrTemplateNotFound) {
+		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	versions, err := h.service.Storage.GetTemplateVersions(c.Request.Context(), id)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	dto := templateToDTO(template)
+	for _, v := range versions {
+		dto.Versions = append(dto.Versions, templateVersionToDTO(v))
+	}
+	c.JSONP(http.StatusOK, dto)
+}
+
+// addTemplateVersion adds new version of template, mailings created from previous versions keep their text.
+func (h *HTTPController) addTemplateVersion(c *gin.Context) {
+	id, err := strconv.ParseInt(c.Param("id"), 10, 64)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	version := templateVersionDTO{}
+	err = c.ShouldBind(&version)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	attributes, err := h.service.Storage.GetAttributeDefinitions(c.Request.Context())
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	err = mailing.ValidateText(version.Text, attributes)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	v, err := h.service.Storage.AddTemplateVersion(c.Request.Context(), id, version.Text)
+	if errors.Is(err, models.ErrTemplateNotFound) {
+		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSONP(http.StatusOK, templateVersionToDTO(v))
+}
+
+// getTemplateVersion retrives given version of template.
+func (h *HTTPController) getTemplateVersion(c *gin.Context) {
+	id, err := strconv.ParseInt(c.Param("id"), 10, 64)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	version, err := strconv.Atoi(c.Param("version"))
+	if err != nil || version < 1 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "version must be a positive number"})
+		return
+	}
+	v, err := h.service.Storage.GetTemplateVersion(c.Request.Context(), id, version)
+	if errors.Is(err, models.ErrTemplateNotFound) {
+		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSONP(http.StatusOK, templateVersionToDTO(v))
+}
+
+// deleteTemplate deletes template with all it's versions, mailings keep their copies of text.
+func (h *HTTPController) deleteTemplate(c *gin.Context) {
+	id, err := strconv.ParseInt(c.Param("id"), 10, 64)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	err = h.service.Storage.DeleteTemplate(c.Request.Context(), id)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+}
+
+// getMailings retrives all mailings.
+// Doesn't convert it to DTO, altough should, so fields would be styled in json way and the status would be human readable :)
+func (h *HTTPController) getMailings(c *gin.Context) {
+	mailings, err := h.service.Storage.GetMailings(c.Request.Context())
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	mailingsDTO := []*mailingDTO{}
+	for _, mailing := range mailings {
+		mailingsDTO = append(mailingsDTO, mailingToDTO(mailing))
+	}
+	c.JSONP(http.StatusOK, mailingsDTO)
+}
+
+// saveMailing adds new mailing.
+func (h *HTTPController) saveMailing(c *gin.Context) {
+	dto := mailingDTO{}
+	err := c.ShouldBind(&dto)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	mailing, ok := h.mailingFromDTO(c, &dto)
+	if !ok {
+		return
+	}
+	mailing.ID = uuid.New()
+	mailing.Status = models.MailingStatusPending
+	err = h.service.Storage.SaveMailing(c.Request.Context(), mailing)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+}
+
+// previewMailing returns audience and sample messages of mailing without saving or sending it.
+func (h *HTTPController) previewMailing(c *gin.Context) {
+	samples, err := strconv.Atoi(c.DefaultQuery("samples", "5"))
+	if err != nil || samples < 0 || samples > 50 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "samples must be from 0 to 50"})
+		return
+	}
+	dto := mailingDTO{}
+	err = c.ShouldBind(&dto)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	mailing, ok := h.mailingFromDTO(c, &dto)
+	if !ok {
+		return
+	}
+	preview, err := h.service.PreviewMailing(c.Request.Context(), mailing, samples)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSONP(http.StatusOK, mailingPreviewToDTO(preview))
+}
+
+// mailingFromDTO validates new mailing and converts it to model.
+// If mailing is invalid, it responds with error and returns false.
+func (h *HTTPController) mailingFromDTO(c *gin.Context, dto *mailingDTO) (*models.Mailing, bool) {
+	msk, err := time.LoadLocation("Europe/Moscow")
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return nil, false
+	}
+	var start, end time.Time
+	if dto.StartTime == "" {
+		start = time.Now()
+	} else {
+		start, err = time.ParseInLocation("02-01-2006 15:04", dto.StartTime, msk)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return nil, false
+		}
+	}
+	if dto.EndTime == "" {
+		end = time.Now().Add(time.Hour)
+	} else {
+		end, err = time.ParseInLocation("02-01-2006 15:04", dto.EndTime, msk)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return nil, false
+		}
+	}
+	ttl, err := parseTTL(dto.TTL)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return nil, false
+	}
+	filter := filterFromDTO(dto.Filter)
+	err = filter.Validate()
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return nil, false
+	}
+	attributes, err := h.service.Storage.GetAttributeDefinitions(c.Request.Context())
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return nil, false
+	}
+	err = filter.ValidateAttributes(attributes)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return nil, false
+	}
+	text, templateVersion := dto.Text, 0
+	if dto.TemplateID != nil {
+		v, ok := h.resolveTemplate(c, *dto.TemplateID, dto.TemplateVersion, dto.Text)
+		if !ok {
+			return nil, false
+		}
+		text, templateVersion = v.Text, v.Version
+	}
+	err = mailing.ValidateText(text, attributes)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return nil, false
+	}
+	variants, err := validateVariants(dto.Variants, attributes)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return nil, false
+	}
+	abTest, err := abTestFromDTO(dto.ABTest, attributes)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return nil, false
+	}
+	if dto.SegmentID != nil {
+		_, err = h.service.Storage.GetSegmentByID(c.Request.Context(), *dto.SegmentID)
+		if errors.Is(err, models.ErrSegmentNotFound) {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return nil, false
+		}
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return nil, false
+		}
+	}
+	audienceMode := models.AudienceMode(dto.AudienceMode)
+	switch audienceMode {
+	case "":
+		audienceMode = models.AudienceModeStart
+	case models.AudienceModeStart, models.AudienceModeSnapshot, models.AudienceModeDynamic:
+	default:
+		c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("unknown audience mode %q", dto.AudienceMode)})
+		return nil, false
+	}
+	if dto.HoldoutPercent < 0 || dto.HoldoutPercent > 99 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "holdout percent must be from 0 to 99"})
+		return nil, false
+	}
+	if dto.MaxSegments < 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "max segments must not be negative"})
+		return nil, false
+	}
+	if dto.SamplePercent < 0 || dto.SamplePercent > 100 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "sample percent must be from 0 to 100"})
+		return nil, false
+	}
+	if dto.MaxRecipients < 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "max recipients must not be negative"})
+		return nil, false
+	}
+	if dto.Budget < 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "budget must not be negative"})
+		return nil, false
+	}
+	m := &models.Mailing{
+		Text:            text,
+		Filter:          filter,
+		StartTime:       start.UTC(),
+		EndTime:         end.UTC(),
+		TTL:             ttl,
+		SegmentID:       dto.SegmentID,
+		AudienceMode:    audienceMode,
+		TemplateID:      dto.TemplateID,
+		TemplateVersion: templateVersion,
+		Variants:        variants,
+		MaxSegments:     dto.MaxSegments,
+		RequireConsent:  dto.RequireConsent,
+		HoldoutPercent:  dto.HoldoutPercent,
+		ABTest:          abTest,
+		SamplePercent:   dto.SamplePercent,
+		MaxRecipients:   dto.MaxRecipients,
+		SampleSeed:      dto.SampleSeed,
+		Budget:          dto.Budget,
+	}
+	err = m.ValidateSegments()
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return nil, false
+	}
+	err = m.ValidateABTest()
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return nil, false
+	}
+	return m, true
+}
+
+// updateMailing changes existing mailing.
+func (h *HTTPController) updateMailing(c *gin.Context) {
+	idURL := c.Param("id")
+	if idURL == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "no id specified"})
+		return
+	}
+	id, err := uuid.Parse(idURL)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	update := mailingUpdateDTO{}
+	err = c.ShouldBind(&update)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	ttl, err := parseTTL(update.TTL)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	filter := filterFromDTO(update.Filter)
+	err = filter.Validate()
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	attributes, err := h.service.Storage.GetAttributeDefinitions(c.Request.Context())
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	err = filter.ValidateAttributes(attributes)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if filter != nil || update.SegmentID != nil {
+		// Audience of snapshot mailing is frozen when mailing is created, changing filter wouldn't change it.
+		current, err := h.service.Storage.GetMailingByID(c.Request.Context(), id)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+		if current.AudienceMode == models.AudienceModeSnapshot {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "filter and segment of snapshot mailing can't be changed, its audience is frozen"})
+			return
+		}
+	}
+	templateVersion := 0
+	if update.TemplateID != nil {
+		v, ok := h.resolveTemplate(c, *update.TemplateID, update.TemplateVersion, update.Text)
+		if !ok {
+			return
+		}
+		update.Text, templateVersion = v.Text, v.Version
+	}
+	if update.Text != "" {
+		err = mailing.ValidateText(update.Text, attributes)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+	}
+	var variants map[string]string
+	if update.Variants != nil {
+		variants, err = validateVariants(update.Variants, attributes)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+	}
+	if update.MaxSegments != nil && *update.MaxSegments < 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "max segments must not be negative"})
+		return
+	}
+	if update.HoldoutPercent != nil && (*update.HoldoutPercent < 0 || *update.HoldoutPercent > 99) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "holdout percent must be from 0 to 99"})
+		return
+	}
+	if update.SamplePercent != nil && (*update.SamplePercent < 0 || *update.SamplePercent > 100) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "sample percent must be from 0 to 100"})
+		return
+	}
+	if update.MaxRecipients != nil && *update.MaxRecipients < 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "max recipients must not be negative"})
+		return
+	}
+	if update.Budget != nil && *update.Budget < 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "budget must not be negative"})
+		return
+	}
+	var abTest *models.ABTest
+	if update.ABTest != nil && len(update.ABTest.Variants) == 0 {
+		// A/B test without variants removes it.
+		abTest = &models.ABTest{}
+	} else if update.ABTest != nil {
+		abTest, err = abTestFromDTO(update.ABTest, attributes)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+	}
+	if update.Text != "" || variants != nil || update.MaxSegments != nil || abTest != nil ||
+		!update.StartTime.IsZero() || !update.EndTime.IsZero() {
+		// Limit and A/B test apply to mailing after update, so unchanged fields are taken from stored mailing.
+		current, err := h.service.Storage.GetMailingByID(c.Request.Context(), id)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+		if update.Text != "" {
+			current.Text = update.Text
+		}
+		if variants != nil {
+			current.Variants = variants
+		}
+		if update.MaxSegments != nil {
+			current.MaxSegments = *update.MaxSegments
+		}
+		if abTest != nil && len(abTest.Variants) == 0 {
+			current.ABTest = nil
+		} else if abTest != nil {
+			current.ABTest = abTest
+		}
+		if !update.StartTime.IsZero() {
+			current.StartTime = update.StartTime
+		}
+		if !update.EndTime.IsZero() {
+			current.EndTime = update.EndTime
+		}
+		err = current.ValidateSegments()
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		err = current.ValidateABTest()
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+	}
+	if update.SegmentID != nil && *update.SegmentID != 0 {
+		_, err = h.service.Storage.GetSegmentByID(c.Request.Context(), *update.SegmentID)
+		if errors.Is(err, models.ErrSegmentNotFound) {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+	}
+	err = h.service.Storage.UpdateMailing(c.Request.Context(), id, &models.MailingUpdate{
+		Text:            update.Text,
+		Filter:          filter,
+		StartTime:       update.StartTime,
+		EndTime:         update.EndTime,
+		TTL:             ttl,
+		SegmentID:       update.SegmentID,
+		TemplateID:      update.TemplateID,
+		TemplateVersion: templateVersion,
+		Variants:        variants,
+		MaxSegments:     update.MaxSegments,
+		RequireConsent:  update.RequireConsent,
+		HoldoutPercent:  update.HoldoutPercent,
+		ABTest:          abTest,
+		SamplePercent:   update.SamplePercent,
+		MaxRecipients:   update.MaxRecipients,
+		SampleSeed:      update.SampleSeed,
+		Budget:          update.Budget,
+	})
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+}
+
+// deleteMailing deletes existing mailing.
+func (h *HTTPController) deleteMailing(c *gin.Context) {
+	idURL := c.Param("id")
+	if idURL == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "no id specified"})
+		return
+	}
+	id, err := uuid.Parse(idURL)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	err = h.service.Storage.DeleteMailing(c.Request.Context(), id)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+}
+
+// getMailingAudience retrives ids of clients in audience snapshot of mailing.
+func (h *HTTPController) getMailingAudience(c *gin.Context) {
+	id, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	mailing, err := h.service.Storage.GetMailingByID(c.Request.Context(), id)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	if mailing.AudienceMode != models.AudienceModeSnapshot {
+		c.JSON(http.StatusConflict, gin.H{"error": "mailing has no audience snapshot"})
+		return
+	}
+	clientIDs, err := h.service.Storage.GetMailingAudience(c.Request.Context(), id)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSONP(http.StatusOK, clientIDs)
+}
+
+// getMailingHoldout retrives ids of clients held out of mailing as control group.
+func (h *HTTPController) getMailingHoldout(c *gin.Context) {
+	id, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	clientIDs, err := h.service.Storage.GetMailingHoldout(c.Request.Context(), id)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSONP(http.StatusOK, clientIDs)
+}
+
+// resendFailed starts follow-up run of finished mailing in background, resending failed messages.
+func (h *HTTPController) resendFailed(c *gin.Context) {
+	idURL := c.Param("id")
+	if idURL == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "no id specified"})
+		return
+	}
+	id, err := uuid.Parse(idURL)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	resend := resendFailedDTO{}
+	err = c.ShouldBindJSON(&resend)
+	if err != nil && !errors.Is(err, io.EOF) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	var end time.Time
+	if resend.EndTime == "" {
+		end = time.Now().Add(time.Hour)
+	} else {
+		msk, err := time.LoadLocation("Europe/Moscow")
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+		end, err = time.ParseInLocation("02-01-2006 15:04", resend.EndTime, msk)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+	}
+	run, err := h.service.ResendFailed(c.Request.Context(), id, end.UTC())
+	if err != nil {
+		if errors.Is(err, mailing.ErrMailingNotFinished) || errors.Is(err, mailing.ErrResendRunning) {
+			c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSONP(http.StatusAccepted, resendStartedDTO{Run: run})
+}
+
+// commonStatistic retrives common statistic for all mailings.
+func (h *HTTPController) commonStatistic(c *gin.Context) {
+	statistic, err := h.service.Storage.CommonStatistic(c.Request.Context())
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	statisticDTO := []*mailingStatisticDTO{}
+	for _, stat := range statistic {
+		statisticDTO = append(statisticDTO, mailingStatisticToDTO(stat))
+	}
+	c.JSONP(http.StatusOK, statisticDTO)
+}
+
+// detailedStatistic retrives detailed statistic for given mailing.
+func (h *HTTPController) detailedStatistic(c *gin.Context) {
+	idURL := c.Param("id")
+	if idURL == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "no id specified"})
+		return
+	}
+	id, err := uuid.Parse(idURL)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	statistic, err := h.service.Storage.DetailedStatistic(c.Request.Context(), id)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	statisticDTO := detailedStatisticToDTO(statistic)
+	c.JSONP(http.StatusOK, statisticDTO)
+}
+
+// Configures active mailing.
+// Sends message to user.
+func (h *HTTPController) sendMessage(c *gin.Context) {
+	idURL := c.Param("id")
+	if idURL == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "no id specified"})
+		return
+	}
+	id, err := strconv.ParseInt(idURL, 10, 64)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	send := sendMessageDTO{}
+	err = c.ShouldBind(&send)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	ttl, err := parseTTL(send.TTL)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	attributes, err := h.service.Storage.GetAttributeDefinitions(c.Request.Context())
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	if send.TemplateID != nil {
+		v, ok := h.resolveTemplate(c, *send.TemplateID, send.TemplateVersion, send.Text)
+		if !ok {
+			return
+		}
+		send.Text = v.Text
+	}
+	err = mailing.ValidateText(send.Text, attributes)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	_, err = h.service.SendMessage(c.Request.Context(), id, send.Text, ttl)
+	if err != nil {
+		if errors.Is(err, models.ErrClientNotFound) {
+			c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+			return
+		}
+		if errors.Is(err, models.ErrClientSuppressed) {
+			c.JSON(http.StatusForbidden, gin.H{"error": err.Error()})
+			return
+		}
+		if errors.Is(err, mailing.ErrMessageExpired) {
+			c.JSON(http.StatusGone, gin.H{"error": err.Error()})
+			return
+		}
+		if errors.Is(err, mailing.ErrTextNotRendered) {
+			c.JSON(http.StatusUnprocessableEntity, gin.H{"error": err.Error()})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+}
+
+// receiveMessage saves message received from client's phone and handles opt-out.
+func (h *HTTPController) receiveMessage(c *gin.Context) {
+	msg := inboundMessageDTO{}
+	err := c.ShouldBind(&msg)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if msg.PhoneNumber == 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "no phone number specified"})
+		return
+	}
+	if utf8.RuneCountInString(msg.Text) > 5000 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "text must be at most 5000 characters long"})
+		return
+	}
+	err = h.service.ReceiveMessage(c.Request.Context(), &models.InboundMessage{
+		PhoneNumber: msg.PhoneNumber,
+		Text:        msg.Text,
+		ReceivedAt:  msg.ReceivedAt.UTC(),
+	})
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+}
+
+// analyzeText retrives encoding and amount of SMS segments text takes.
+func (h *HTTPController) analyzeText(c *gin.Context) {
+	text := textDTO{}
+	err := c.ShouldBind(&text)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSONP(http.StatusOK, textAnalysisToDTO(models.AnalyzeText(text.Text)))
+}
+
+// followLink redirects client from short link in message to the original URL.
+func (h *HTTPController) followLink(c *gin.Context) {
+	url, err := h.service.Storage.ClickLink(c.Request.Context(), c.Param("code"))
+	if errors.Is(err, models.ErrLinkNotFound) {
+		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	c.Redirect(http.StatusFound, url)
+}
+
+// getDeadLetters retrives messages, that exhausted all send retries.
+// Messages can be narrowed down to one mailing with "mailingID" query parameter.
+func (h *HTTPController) getDeadLetters(c *gin.Context) {
+	var mailingID *uuid.UUID
+	if idQuery := c.Query("mailingID"); idQuery != "" {
+		id, err := uuid.Parse(idQuery)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		mailingID = &id
+	}
+	messages, err := h.service.GetDeadLetters(c.Request.Context(), mailingID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	messagesDTO := []*messageDTO{}
+	for _, msg := range messages {
+		messagesDTO = append(messagesDTO, messageToDTO(msg))
+	}
+	c.JSONP(http.StatusOK, messagesDTO)
+}
+
+// replayDeadLetters resends selected failed messages.
+func (h *HTTPController) replayDeadLetters(c *gin.Context) {
+	replay := deadLetterReplayDTO{}
+	err := c.ShouldBind(&replay)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if len(replay.MessageIDs) == 0 && replay.MailingID == nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "no messages or mailing specified"})
+		return
+	}
+	var end time.Time
+	if replay.EndTime != "" {
+		msk, err := time.LoadLocation("Europe/Moscow")
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+		end, err = time.ParseInLocation("02-01-2006 15:04", replay.EndTime, msk)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+	}
+	result, err := h.service.ReplayDeadLetters(c.Request.Context(), &models.DeadLetterReplay{
+		MessageIDs: replay.MessageIDs,
+		MailingID:  replay.MailingID,
+		EndTime:    end.UTC(),
+	})
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSONP(http.StatusOK, replayResultToDTO(result))
+}
+
+// resolveTemplate returns referenced template version, which text replaces given one, so text must be empty.
+// If reference is invalid, it responds with error and returns false.
+func (h *HTTPController) resolveTemplate(c *gin.Context, templateID int64, version int, text string) (*models.TemplateVersion, bool) {
+	if text != "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "text can't be given along with template"})
+		return nil, false
+	}
+	v, err := h.service.Storage.GetTemplateVersion(c.Request.Context(), templateID, version)
+	if errors.Is(err, models.ErrTemplateNotFound) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return nil, false
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return nil, false
+	}
+	return v, true
+}
+
+// parseTTL parses message time to live in time.ParseDuration format, empty string means that message never expires.
+func parseTTL(ttl string) (time.Duration, error) {
+	if ttl == "" {
+		return 0, nil
+	}
+	d, err := time.ParseDuration(ttl)
+	if err != nil {
+		return 0, errors.Wrap(err, "parse ttl")
+	}
+	if d < 0 {
+		return 0, errors.New("ttl must not be negative")
+	}
+	return d, nil
+}
+
+// validateSegmentName checks that segment name fits into storage.
+func validateSegmentName(name string) error {
+	if name == "" || utf8.RuneCountInString(name) > 100 {
+		return errors.Errorf("segment name %q must be from 1 to 100 characters long", name)
+	}
+	return nil
+}
+
+// abTestFromDTO checks A/B test of mailing, nil A/B test is returned as is.
+func abTestFromDTO(dto *abTestDTO, attributes []*models.AttributeDefinition) (*models.ABTest, error) {
+	if dto == nil {
+		return nil, nil
+	}
+	if len(dto.Variants) == 0 {
+		return nil, errors.New("A/B test must have at least one variant besides mailing's text")
+	}
+	for i, text := range dto.Variants {
+		if text == "" {
+			return nil, errors.Errorf("text of A/B variant %d is empty", i+2)
+		}
+		err := mailing.ValidateText(text, attributes)
+		if err != nil {
+			return nil, errors.Wrapf(err, "A/B variant %d", i+2)
+		}
+	}
+	if dto.CellPercent < 1 || dto.CellPercent*(len(dto.Variants)+1) > 100 {
+		return nil, errors.New("cell percent must be positive and test cells of all variants must fit into audience")
+	}
+	wait, err := time.ParseDuration(dto.Wait)
+	if err != nil {
+		return nil, errors.Wrap(err, "parse wait")
+	}
+	if wait < 0 {
+		return nil, errors.New("wait must not be negative")
+	}
+	metric := models.ABTestMetric(dto.Metric)
+	switch metric {
+	case "":
+		metric = models.ABTestMetricDelivery
+	case models.ABTestMetricDelivery, models.ABTestMetricClick:
+	default:
+		return nil, errors.Errorf("unknown A/B test metric %q", dto.Metric)
+	}
+	return &models.ABTest{
+		Variants:    dto.Variants,
+		CellPercent: dto.CellPercent,
+		Wait:        wait,
+		Metric:      metric,
+	}, nil
+}
+
+// validateVariants checks localized texts of mailing and returns them keyed by normalized locale.
+func validateVariants(variants map[string]string, attributes []*models.AttributeDefinition) (map[string]string, error) {
+	normalized := map[string]string{}
+	for locale, text := range variants {
+		key, err := models.NormalizeLocale(locale)
+		if err != nil {
+			return nil, err
+		}
+		if key == "" {
+			return nil, errors.New("variant locale must not be empty")
+		}
+		if _, ok := normalized[key]; ok {
+			return nil, errors.Errorf("duplicate variant locale %q", key)
+		}
+		if text == "" {
+			return nil, errors.Errorf("text of variant %q must not be empty", key)
+		}
+		err = mailing.ValidateText(text, attributes)
+		if err != nil {
+			return nil, errors.Wrapf(err, "variant %q", key)
+		}
+		normalized[key] = text
+	}
+	return normalized, nil
+}
+
+// validateTags checks that tags fit into storage.
+func validateTags(tags []string) error {
+	for _, tag := range tags {
+		if tag == "" || utf8.RuneCountInString(tag) > 100 {
+			return errors.Errorf("tag %q must be from 1 to 100 characters long", tag)
+		}
+	}
+	return nil
 }
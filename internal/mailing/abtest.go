@@ -0,0 +1,89 @@
+package mailing
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/pkg/errors"
+	"go.uber.org/zap"
+
+	"mailing/internal/models"
+)
+
+// runABTest runs the due stage of mailing's A/B test and reports whether mailing waits for the rollout.
+// Variants of mailing's text are sent to their test cells first and the rollout is scheduled after the wait,
+// so that it is resumed even if service restarts. Then the winner is sent to the rest of clients.
+// Both stages are recorded as run 0.
+func (m *MailingService) runABTest(ctx context.Context, mailing *models.Mailing, clients []*models.Client, suppressed int) (bool, error) {
+	l := zap.L()
+	test, rest := []*models.Client{}, []*models.Client{}
+	for _, client := range clients {
+		if mailing.TestCell(client.ID) > 0 {
+			test = append(test, client)
+		} else {
+			rest = append(rest, client)
+		}
+	}
+	if mailing.ABTest.RolloutAt.IsZero() {
+		_, err := m.run(ctx, mailing, test, 0, suppressed)
+		if err != nil {
+			return false, err
+		}
+		rolloutAt := time.Now().Add(mailing.ABTest.Wait)
+		err = m.Storage.ScheduleABTestRollout(ctx, mailing.ID, rolloutAt)
+		if err != nil {
+			return false, errors.Wrap(err, "schedule A/B test rollout")
+		}
+		l.Info(fmt.Sprintf("A/B test sent, winner is rolled out at %v\nMailing: %v", rolloutAt, mailing.ID))
+		return true, nil
+	}
+	stats, err := m.Storage.GetVariantStats(ctx, mailing.ID)
+	if err != nil {
+		// Mailing's own text is sent if results are unknown, rather than not sending to the rest at all.
+		l.Error(fmt.Sprintf("FAIL: get variant stats\nMailing: %v; Error: %v", mailing.ID, err))
+	}
+	mailing.ABTest.Winner = chooseWinner(stats, mailing.ABTest.Metric)
+	l.Info(fmt.Sprintf("A/B test finished, variant %d won\nMailing: %v", mailing.ABTest.Winner, mailing.ID))
+	err = m.Storage.SaveABTestWinner(ctx, mailing.ID, mailing.ABTest.Winner)
+	if err != nil {
+		l.Error(fmt.Sprintf("FAIL: save A/B test winner\nMailing: %v; Error: %v", mailing.ID, err))
+	}
+	_, err = m.run(ctx, mailing, rest, 0, 0)
+	return false, err
+}
+
+// chooseWinner returns variant with the highest rate by given metric, the lowest numbered variant wins a tie.
+// Variant 1 wins if no variant was sent.
+func chooseWinner(stats []models.VariantStats, metric models.ABTestMetric) int {
+	winner, best := 1, -1.0
+	for _, s := range stats {
+		if rate := s.Rate(metric); rate > best {
+			winner, best = s.Variant, rate
+		}
+	}
+	return winner
+}
+
+// newVariantLocalizers parses texts of all A/B test variants of mailing, localizer of variant n is at index n-1.
+func newVariantLocalizers(mailing *models.Mailing, attributes []*models.AttributeDefinition) []*localizer {
+	localizers := []*localizer{}
+	for _, text := range mailing.Texts() {
+		variant := *mailing
+		variant.Text = text
+		localizers = append(localizers, newLocalizer(&variant, attributes))
+	}
+	return localizers
+}
+
+// variantFor returns the number of A/B test variant sent to client, 0 if mailing has no A/B test.
+// The winner is sent to everyone once it is chosen.
+func variantFor(mailing *models.Mailing, clientID int64) int {
+	if mailing.ABTest == nil {
+		return 0
+	}
+	if mailing.ABTest.Winner > 0 {
+		return mailing.ABTest.Winner
+	}
+	return max(mailing.TestCell(clientID), 1)
+}
@@ -0,0 +1,227 @@
+package sender
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+// ErrSenderClosed is returned when message is sent after sender was closed.
+var ErrSenderClosed = errors.New("sender is closed")
+
+// batcher collects messages sent concurrently and sends them to sender API in batches.
+type batcher struct {
+	sender *Sender
+	size   int
+	wait   time.Duration
+	queue  chan *batchItem
+	// mu guards closed, so that nothing is queued after loop drained the queue.
+	mu      sync.RWMutex
+	closed  bool
+	stop    chan struct{}
+	stopped chan struct{}
+	flushes sync.WaitGroup
+}
+
+// Batch item states, item is sent only if it was not canceled before it's batch was formed.
+const (
+	itemQueued int = iota
+	itemTaken
+	itemCanceled
+)
+
+type batchItem struct {
+	ctx     context.Context
+	payload payloadSend
+	mu      sync.Mutex
+	state   int
+	done    chan error
+}
+
+// take marks item as taken into batch, reporting false if it's sender gave up on it.
+func (i *batchItem) take() bool {
+	i.mu.Lock()
+	defer i.mu.Unlock()
+	if i.state == itemCanceled {
+		return false
+	}
+	i.state = itemTaken
+	return true
+}
+
+// cancel marks item as canceled, reporting false if it is already taken into batch.
+func (i *batchItem) cancel() bool {
+	i.mu.Lock()
+	defer i.mu.Unlock()
+	if i.state == itemTaken {
+		return false
+	}
+	i.state = itemCanceled
+	return true
+}
+
+type payloadBatch struct {
+	Messages []payloadSend `json:"messages"`
+}
+
+func newBatcher(sender *Sender, size int, wait time.Duration) *batcher {
+	b := &batcher{
+		sender:  sender,
+		size:    size,
+		wait:    wait,
+		queue:   make(chan *batchItem, size),
+		stop:    make(chan struct{}),
+		stopped: make(chan struct{}),
+	}
+	go b.loop()
+	return b
+}
+
+// send queues message and waits until the batch it was put in is sent.
+// Once message is taken into batch, the result of the batch is returned even if context is done,
+// so that message, that may still be sent, is never reported as failed.
+func (b *batcher) send(ctx context.Context, payload payloadSend) error {
+	item := &batchItem{
+		ctx:     ctx,
+		payload: payload,
+		done:    make(chan error, 1),
+	}
+	err := b.enqueue(ctx, item)
+	if err != nil {
+		return err
+	}
+	select {
+	case err := <-item.done:
+		return err
+	case <-ctx.Done():
+		if item.cancel() {
+			return ctx.Err()
+		}
+		return <-item.done
+	}
+}
+
+// enqueue puts item in the queue unless batcher is closed.
+func (b *batcher) enqueue(ctx context.Context, item *batchItem) error {
+	b.mu.RLock()
+	defer b.mu.RUnlock()
+	if b.closed {
+		return ErrSenderClosed
+	}
+	select {
+	case b.queue <- item:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// close stops forming batches, sends messages left in the queue and waits for batches being sent.
+func (b *batcher) close() {
+	b.mu.Lock()
+	if b.closed {
+		b.mu.Unlock()
+		return
+	}
+	b.closed = true
+	close(b.stop)
+	b.mu.Unlock()
+	<-b.stopped
+	b.flushes.Wait()
+}
+
+// loop forms batches from queued messages, batch is sent when it is full or when its wait time is over.
+func (b *batcher) loop() {
+	defer close(b.stopped)
+	for {
+		var items []*batchItem
+		select {
+		case item := <-b.queue:
+			items = append(items, item)
+		case <-b.stop:
+			b.drain()
+			return
+		}
+		timer := time.NewTimer(b.wait)
+	collect:
+		for len(items) < b.size {
+			select {
+			case item := <-b.queue:
+				items = append(items, item)
+			case <-timer.C:
+				break collect
+			case <-b.stop:
+				break collect
+			}
+		}
+		timer.Stop()
+		b.start(items)
+	}
+}
+
+// drain sends messages left in the queue, nothing is queued anymore when it is called.
+func (b *batcher) drain() {
+	items := []*batchItem{}
+	for {
+		select {
+		case item := <-b.queue:
+			items = append(items, item)
+			if len(items) == b.size {
+				b.start(items)
+				items = []*batchItem{}
+			}
+		default:
+			if len(items) > 0 {
+				b.start(items)
+			}
+			return
+		}
+	}
+}
+
+// start sends batch in background.
+func (b *batcher) start(items []*batchItem) {
+	b.flushes.Add(1)
+	go func() {
+		defer b.flushes.Done()
+		b.flush(items)
+	}()
+}
+
+// flush sends batch and reports the result to every message in it.
+// Batch is limited by the earliest deadline of it's messages and by client timeout.
+func (b *batcher) flush(items []*batchItem) {
+	taken := make([]*batchItem, 0, len(items))
+	payload := payloadBatch{
+		Messages: make([]payloadSend, 0, len(items)),
+	}
+	var deadline time.Time
+	for _, item := range items {
+		if !item.take() {
+			continue
+		}
+		taken = append(taken, item)
+		payload.Messages = append(payload.Messages, item.payload)
+		if d, ok := item.ctx.Deadline(); ok && (deadline.IsZero() || d.Before(deadline)) {
+			deadline = d
+		}
+	}
+	if len(taken) == 0 {
+		return
+	}
+	ctx := context.Background()
+	if !deadline.IsZero() {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithDeadline(ctx, deadline)
+		defer cancel()
+	}
+	err := b.sender.post(ctx, b.sender.apiURL+b.sender.config.BatchPath, payload)
+	if err != nil {
+		err = errors.Wrap(err, "send batch")
+	}
+	for _, item := range taken {
+		item.done <- err
+	}
+}
@@ -0,0 +1,119 @@
+package sender
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"mailing/internal/config"
+)
+
+// batchAPI is fake sender API, that records ids of messages received in batches.
+type batchAPI struct {
+	mu  sync.Mutex
+	ids []int64
+}
+
+func (a *batchAPI) start(t *testing.T) *httptest.Server {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		payload := payloadBatch{}
+		err := json.NewDecoder(r.Body).Decode(&payload)
+		if err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		a.mu.Lock()
+		for _, msg := range payload.Messages {
+			a.ids = append(a.ids, msg.ID)
+		}
+		a.mu.Unlock()
+	}))
+	t.Cleanup(server.Close)
+	return server
+}
+
+func (a *batchAPI) received() []int64 {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	return append([]int64{}, a.ids...)
+}
+
+func newTestConfig(url string) *config.SenderConfig {
+	return &config.SenderConfig{
+		URL:                 url,
+		JWTs:                []string{"token"},
+		Timeout:             10 * time.Second,
+		MaxIdleConns:        100,
+		MaxConnsPerHost:     100,
+		IdleConnTimeout:     90 * time.Second,
+		TLSHandshakeTimeout: 10 * time.Second,
+		// Test server has self-signed certificate.
+		TLSInsecureSkipVerify: true,
+	}
+}
+
+func newBatchSender(url string, wait time.Duration) *Sender {
+	cfg := newTestConfig(url)
+	cfg.BatchPath = "/batch"
+	cfg.BatchSize = 10
+	cfg.BatchWait = wait
+	return New(NewHTTPClient(cfg), cfg)
+}
+
+func TestBatchSkipsCanceledMessage(t *testing.T) {
+	api := &batchAPI{}
+	s := newBatchSender(api.start(t).URL, 100*time.Millisecond)
+	defer s.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	var canceledErr error
+	wg := &sync.WaitGroup{}
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		canceledErr = s.Send(ctx, 1, 79990000001, "canceled")
+	}()
+	err := s.Send(context.Background(), 2, 79990000002, "sent")
+	wg.Wait()
+
+	if err != nil {
+		t.Fatalf("send: %v", err)
+	}
+	if !errors.Is(canceledErr, context.DeadlineExceeded) {
+		t.Fatalf("canceled send returned %v, want deadline exceeded", canceledErr)
+	}
+	if ids := api.received(); len(ids) != 1 || ids[0] != 2 {
+		t.Fatalf("API received %v, want [2]", ids)
+	}
+}
+
+func TestCloseSendsQueuedMessages(t *testing.T) {
+	api := &batchAPI{}
+	s := newBatchSender(api.start(t).URL, time.Hour)
+
+	errs := make(chan error, 1)
+	go func() {
+		errs <- s.Send(context.Background(), 1, 79990000001, "queued")
+	}()
+	// Message has to be queued before Close.
+	time.Sleep(20 * time.Millisecond)
+	s.Close()
+
+	err := <-errs
+	if err != nil {
+		t.Fatalf("send: %v", err)
+	}
+	if ids := api.received(); len(ids) != 1 || ids[0] != 1 {
+		t.Fatalf("API received %v, want [1]", ids)
+	}
+	err = s.Send(context.Background(), 2, 79990000002, "late")
+	if !errors.Is(err, ErrSenderClosed) {
+		t.Fatalf("send after close returned %v, want %v", err, ErrSenderClosed)
+	}
+}
@@ -0,0 +1,51 @@
+package sender
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// limiter spaces requests evenly, so that no more than rate of them are made per second.
+// Nil limiter doesn't limit anything.
+type limiter struct {
+	mu       sync.Mutex
+	interval time.Duration
+	next     time.Time
+}
+
+// newLimiter returns limiter allowing rate requests per second, or nil if rate is not positive.
+func newLimiter(rate int) *limiter {
+	if rate <= 0 {
+		return nil
+	}
+	return &limiter{interval: time.Second / time.Duration(rate)}
+}
+
+// wait blocks until the next request is allowed or context is done.
+func (l *limiter) wait(ctx context.Context) error {
+	if l == nil {
+		return nil
+	}
+	l.mu.Lock()
+	now := time.Now()
+	if l.next.Before(now) {
+		l.next = now
+	}
+	at := l.next
+	l.next = l.next.Add(l.interval)
+	l.mu.Unlock()
+
+	delay := time.Until(at)
+	if delay <= 0 {
+		return nil
+	}
+	timer := time.NewTimer(delay)
+	defer timer.Stop()
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-timer.C:
+		return nil
+	}
+}
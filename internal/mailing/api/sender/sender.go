@@ -3,8 +3,11 @@ package sender
 import (
 	"bytes"
 	"context"
+	"crypto/tls"
 	"encoding/json"
 	"fmt"
+	"io"
+	"net"
 	"net/http"
 	"time"
 
@@ -15,20 +18,59 @@ import (
 )
 
 // Sender is MessageSender implementation via API.
+// It is safe for concurrent use, all requests share one pooled http client.
 type Sender struct {
 	client *http.Client
 	apiURL string
 	config *config.SenderConfig
+	tokens *tokenRing
+	batch  *batcher
+	limit  *limiter
 }
 
 // New returns new MessageSender implementation via Sender.
+// Client should be shared between all senders, see NewHTTPClient.
 func New(client *http.Client, config *config.SenderConfig) *Sender {
-	return &Sender{
+	s := &Sender{
 		client: client,
-		// apiURL should be in config, parsed from json, as it is not a sensitive info
-		// but for the sake of simplicity it is defined here.
-		apiURL: "https://probe.fbrq.cloud/v1",
+		apiURL: config.URL,
 		config: config,
+		tokens: newTokenRing(config.JWTs, config.JWTExpiryWarning, config.JWTRejectCooldown),
+		limit:  newLimiter(config.RateLimit),
+	}
+	if config.BatchPath != "" && config.BatchSize > 1 {
+		s.batch = newBatcher(s, config.BatchSize, config.BatchWait)
+	}
+	return s
+}
+
+// NewHTTPClient returns http client with connection pool configured for sender API.
+func NewHTTPClient(config *config.SenderConfig) *http.Client {
+	dialer := &net.Dialer{
+		Timeout:   config.Timeout,
+		KeepAlive: config.IdleConnTimeout,
+	}
+	tr := &http.Transport{
+		Proxy:               http.ProxyFromEnvironment,
+		DialContext:         dialer.DialContext,
+		MaxIdleConns:        config.MaxIdleConns,
+		MaxIdleConnsPerHost: config.MaxIdleConns,
+		MaxConnsPerHost:     config.MaxConnsPerHost,
+		IdleConnTimeout:     config.IdleConnTimeout,
+		TLSHandshakeTimeout: config.TLSHandshakeTimeout,
+		TLSClientConfig: &tls.Config{
+			MinVersion:         tls.VersionTLS12,
+			InsecureSkipVerify: config.TLSInsecureSkipVerify,
+		},
+		ForceAttemptHTTP2: config.HTTP2,
+	}
+	if !config.HTTP2 {
+		// Non-nil empty map disables HTTP/2 upgrade.
+		tr.TLSNextProto = map[string]func(string, *tls.Conn) http.RoundTripper{}
+	}
+	return &http.Client{
+		Transport: tr,
+		Timeout:   config.Timeout,
 	}
 }
 
@@ -39,34 +81,57 @@ type payloadSend struct {
 }
 
 // Send sends message via MessageSender.
+// In batch mode message is queued and sent together with others.
+// Messages are sent no faster than rate limit, waiting for it's turn counts against context deadline.
 func (s *Sender) Send(ctx context.Context, msgID int64, clientPhone int64, text string) error {
-	l := zap.L()
-	url := s.apiURL + "/send"
-	url += fmt.Sprintf("/%d", msgID)
-
-	// Form payload.
+	err := s.limit.wait(ctx)
+	if err != nil {
+		return errors.Wrap(err, "wait for rate limit")
+	}
 	payload := payloadSend{
 		ID:    msgID,
 		Phone: clientPhone,
 		Text:  text,
 	}
+	if s.batch != nil {
+		return s.batch.send(ctx, payload)
+	}
+	return s.post(ctx, fmt.Sprintf("%s/send/%d", s.apiURL, msgID), payload)
+}
+
+// Close stops batch mode, messages already queued are sent before it returns.
+// Messages sent after Close fail with ErrSenderClosed.
+func (s *Sender) Close() {
+	if s.batch != nil {
+		s.batch.close()
+	}
+}
+
+// RateLimit returns the maximum amount of messages sent per second, 0 means no limit.
+func (s *Sender) RateLimit() int {
+	return max(s.config.RateLimit, 0)
+}
+
+// post sends payload to sender API.
+func (s *Sender) post(ctx context.Context, url string, payload any) error {
+	l := zap.L()
+	// Form payload.
 	payloadMarshaled, err := json.Marshal(payload)
 	if err != nil {
 		return errors.Wrap(err, "marshal payload")
 	}
 
 	// Form request.
-	tr := &http.Transport{
-		MaxIdleConns:       10,
-		IdleConnTimeout:    30 * time.Second,
-		DisableCompression: true,
-	}
-	s.client = &http.Client{Transport: tr}
 	req, err := http.NewRequestWithContext(ctx, http.MethodPost, url, bytes.NewReader(payloadMarshaled))
 	if err != nil {
 		return errors.Wrap(err, "form request")
 	}
-	req.Header.Add("Authorization", fmt.Sprintf("Bearer %s", s.config.JWT))
+	jwt, err := s.tokens.get(time.Now())
+	if err != nil {
+		return errors.Wrap(err, "get token")
+	}
+	req.Header.Add("Authorization", fmt.Sprintf("Bearer %s", jwt))
+	req.Header.Add("Content-Type", "application/json")
 
 	// Send request.
 	l.Info(fmt.Sprintf("Sending request to %s...", url))
@@ -76,7 +141,15 @@ func (s *Sender) Send(ctx context.Context, msgID int64, clientPhone int64, text
 		return errors.Wrap(err, "send request")
 	}
 	defer resp.Body.Close()
+	// Body has to be read to the end, so the connection could be reused.
+	defer io.Copy(io.Discard, resp.Body)
 	// Check response.
+	if resp.StatusCode == http.StatusUnauthorized || resp.StatusCode == http.StatusForbidden {
+		l.Error("FAIL")
+		// Token is rejected, so the next requests would use another one until cooldown is over.
+		s.tokens.reject(jwt, time.Now())
+		return errors.Errorf("token rejected with status %s", resp.Status)
+	}
 	if resp.StatusCode != http.StatusOK {
 		l.Error("FAIL")
 		l.Debug(fmt.Sprint("Status: ", resp.Status))
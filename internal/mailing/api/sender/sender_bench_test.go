@@ -0,0 +1,89 @@
+package sender
+
+import (
+	"context"
+	"crypto/tls"
+	"net/http"
+	"net/http/httptest"
+	"runtime"
+	"testing"
+	"time"
+
+	"mailing/internal/config"
+)
+
+// newTestAPI starts fake sender API over TLS, that takes given time to handle a request.
+func newTestAPI(tb testing.TB, latency time.Duration) *httptest.Server {
+	server := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		time.Sleep(latency)
+		w.WriteHeader(http.StatusOK)
+	}))
+	tb.Cleanup(server.Close)
+	return server
+}
+
+// BenchmarkTransport compares building transport per call, as sender did before, with the shared pooled client.
+func BenchmarkTransport(b *testing.B) {
+	server := newTestAPI(b, 0)
+	cfg := newTestConfig(server.URL)
+	ctx := context.Background()
+	b.Run("per-call", func(b *testing.B) {
+		for i := 0; i < b.N; i++ {
+			tr := &http.Transport{
+				MaxIdleConns:       10,
+				IdleConnTimeout:    30 * time.Second,
+				DisableCompression: true,
+				TLSClientConfig:    &tls.Config{InsecureSkipVerify: true},
+			}
+			s := New(&http.Client{Transport: tr}, cfg)
+			err := s.Send(ctx, int64(i), 79990000000, "text")
+			if err != nil {
+				b.Fatal(err)
+			}
+			// Otherwise connections of every call would pile up until the end of benchmark.
+			tr.CloseIdleConnections()
+		}
+	})
+	b.Run("pooled", func(b *testing.B) {
+		s := New(NewHTTPClient(cfg), cfg)
+		for i := 0; i < b.N; i++ {
+			err := s.Send(ctx, int64(i), 79990000000, "text")
+			if err != nil {
+				b.Fatal(err)
+			}
+		}
+	})
+}
+
+// BenchmarkBatch compares concurrent single sends with batch mode,
+// when sender API is slow and the amount of connections to it is limited.
+func BenchmarkBatch(b *testing.B) {
+	const senders = 64
+	server := newTestAPI(b, 2*time.Millisecond)
+	ctx := context.Background()
+	run := func(b *testing.B, cfg *config.SenderConfig) {
+		cfg.MaxConnsPerHost = 4
+		s := New(NewHTTPClient(cfg), cfg)
+		defer s.Close()
+		b.SetParallelism(max(senders/runtime.GOMAXPROCS(0), 1))
+		b.RunParallel(func(pb *testing.PB) {
+			for pb.Next() {
+				err := s.Send(ctx, 1, 79990000000, "text")
+				if err != nil {
+					b.Error(err)
+					return
+				}
+			}
+		})
+	}
+	b.Run("single", func(b *testing.B) {
+		run(b, newTestConfig(server.URL))
+	})
+	b.Run("batch", func(b *testing.B) {
+		cfg := newTestConfig(server.URL)
+		cfg.BatchPath = "/batch"
+		cfg.BatchSize = senders
+		cfg.BatchWait = 2 * time.Millisecond
+		run(b, cfg)
+	})
+}
@@ -0,0 +1,113 @@
+package sender
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/pkg/errors"
+	"go.uber.org/zap"
+)
+
+// ErrNoValidToken is returned when all sender API tokens are expired or recently rejected.
+var ErrNoValidToken = errors.New("no valid sender token")
+
+// tokenRing rotates sender API tokens, swapping to the next valid one when the current expires or is rejected.
+// Sender API may reject token for reasons other than authentication, so rejected token is only benched for cooldown.
+type tokenRing struct {
+	mu         sync.Mutex
+	tokens     []*token
+	current    int
+	warnBefore time.Duration
+	cooldown   time.Duration
+}
+
+type token struct {
+	value string
+	// expiresAt is zero if token has no expiry.
+	expiresAt time.Time
+	// benchedUntil is the time until which rejected token is not used.
+	benchedUntil time.Time
+	warned       bool
+}
+
+func newTokenRing(values []string, warnBefore, cooldown time.Duration) *tokenRing {
+	l := zap.L()
+	r := &tokenRing{
+		warnBefore: warnBefore,
+		cooldown:   cooldown,
+	}
+	for i, value := range values {
+		expiresAt, err := parseExpiry(value)
+		if err != nil {
+			l.Warn(fmt.Sprintf("Can't read expiry of sender token #%d, assuming it never expires\nError: %v", i, err))
+		}
+		r.tokens = append(r.tokens, &token{
+			value:     value,
+			expiresAt: expiresAt,
+		})
+	}
+	return r
+}
+
+// get returns the current valid token, swapping to the next one if the current is no longer valid.
+func (r *tokenRing) get(now time.Time) (string, error) {
+	l := zap.L()
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for range r.tokens {
+		t := r.tokens[r.current]
+		expired := !t.expiresAt.IsZero() && !now.Before(t.expiresAt)
+		if !expired && !now.Before(t.benchedUntil) {
+			if !t.expiresAt.IsZero() && !t.warned && t.expiresAt.Sub(now) < r.warnBefore {
+				l.Warn(fmt.Sprintf("Sender token #%d expires at %v", r.current, t.expiresAt))
+				t.warned = true
+			}
+			return t.value, nil
+		}
+		next := (r.current + 1) % len(r.tokens)
+		l.Warn(fmt.Sprintf("Sender token #%d is expired or rejected, swapping to token #%d", r.current, next))
+		r.current = next
+	}
+	return "", ErrNoValidToken
+}
+
+// reject benches token rejected by sender API, so it is not used until cooldown is over.
+func (r *tokenRing) reject(value string, now time.Time) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for _, t := range r.tokens {
+		if t.value == value {
+			t.benchedUntil = now.Add(r.cooldown)
+		}
+	}
+}
+
+// parseExpiry returns expiry time from "exp" claim of JWT, zero time if there is no such claim.
+// Token signature is not verified, as it is the job of sender API.
+// Claim may be fractional, as allowed by RFC 7519, then it is rounded down to seconds.
+func parseExpiry(jwt string) (time.Time, error) {
+	parts := strings.Split(jwt, ".")
+	if len(parts) != 3 {
+		return time.Time{}, errors.New("token is not a JWT")
+	}
+	// Some issuers keep base64 padding, though JWT omits it.
+	payload, err := base64.RawURLEncoding.DecodeString(strings.TrimRight(parts[1], "="))
+	if err != nil {
+		return time.Time{}, errors.Wrap(err, "decode payload")
+	}
+	claims := struct {
+		Exp float64 `json:"exp"`
+	}{}
+	err = json.Unmarshal(payload, &claims)
+	if err != nil {
+		return time.Time{}, errors.Wrap(err, "unmarshal claims")
+	}
+	if claims.Exp == 0 {
+		return time.Time{}, nil
+	}
+	return time.Unix(int64(claims.Exp), 0), nil
+}
@@ -0,0 +1,176 @@
+package sender
+
+import (
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+	"go.uber.org/zap/zaptest/observer"
+)
+
+// newTestJWT returns unsigned JWT with given payload.
+func newTestJWT(payload string) string {
+	header := base64.RawURLEncoding.EncodeToString([]byte(`{"alg":"HS256","typ":"JWT"}`))
+	return header + "." + base64.RawURLEncoding.EncodeToString([]byte(payload)) + ".signature"
+}
+
+func TestParseExpiry(t *testing.T) {
+	tests := []struct {
+		name    string
+		jwt     string
+		want    time.Time
+		wantErr bool
+	}{
+		{name: "exp", jwt: newTestJWT(`{"sub":"mailing","exp":1700000000}`), want: time.Unix(1700000000, 0)},
+		{name: "fractional exp", jwt: newTestJWT(`{"exp":1700000000.75}`), want: time.Unix(1700000000, 0)},
+		{name: "no exp", jwt: newTestJWT(`{"sub":"mailing"}`)},
+		{name: "zero exp", jwt: newTestJWT(`{"exp":0}`)},
+		{name: "empty claims", jwt: newTestJWT(`{}`)},
+		{name: "empty string", jwt: "", wantErr: true},
+		{name: "not a JWT", jwt: "token", wantErr: true},
+		{name: "too many parts", jwt: newTestJWT(`{"exp":1700000000}`) + ".extra", wantErr: true},
+		{name: "padded payload", jwt: "header." + base64.URLEncoding.EncodeToString([]byte(`{"exp":10}`)) + ".signature", want: time.Unix(10, 0)},
+		{name: "bad base64", jwt: "header.!!!.signature", wantErr: true},
+		{name: "payload is not JSON", jwt: newTestJWT(`exp`), wantErr: true},
+		{name: "exp is not a number", jwt: newTestJWT(`{"exp":"1700000000"}`), wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := parseExpiry(tt.jwt)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("parseExpiry error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if !got.Equal(tt.want) {
+				t.Errorf("parseExpiry = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+// newExpiringJWT returns unsigned JWT expiring at given time.
+func newExpiringJWT(expiresAt time.Time) string {
+	return newTestJWT(fmt.Sprintf(`{"exp":%d}`, expiresAt.Unix()))
+}
+
+func TestTokenRingGet(t *testing.T) {
+	now := time.Unix(1700000000, 0)
+	valid := newExpiringJWT(now.Add(time.Hour))
+	expired := newExpiringJWT(now.Add(-time.Hour))
+	tests := []struct {
+		name    string
+		tokens  []string
+		want    string
+		wantErr error
+	}{
+		{name: "token without expiry", tokens: []string{"token"}, want: "token"},
+		{name: "the first valid token", tokens: []string{valid, "token"}, want: valid},
+		{name: "expired tokens are skipped", tokens: []string{expired, expired, valid}, want: valid},
+		{name: "token expiring now is expired", tokens: []string{newExpiringJWT(now), "token"}, want: "token"},
+		{name: "all tokens expired", tokens: []string{expired, expired}, wantErr: ErrNoValidToken},
+		{name: "no tokens", tokens: nil, wantErr: ErrNoValidToken},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := newTokenRing(tt.tokens, time.Minute, time.Minute)
+			got, err := r.get(now)
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("get error = %v, want %v", err, tt.wantErr)
+			}
+			if got != tt.want {
+				t.Errorf("get = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestTokenRingRotation(t *testing.T) {
+	const cooldown = time.Minute
+	now := time.Unix(1700000000, 0)
+	r := newTokenRing([]string{"a", "b", "c"}, 0, cooldown)
+	// Every step rejects the current token at given time, if there is one, and gets the next.
+	steps := []struct {
+		reject  string
+		at      time.Time
+		want    string
+		wantErr error
+	}{
+		{at: now, want: "a"},
+		{at: now, want: "a"},
+		{reject: "a", at: now, want: "b"},
+		{reject: "b", at: now, want: "c"},
+		{reject: "c", at: now, wantErr: ErrNoValidToken},
+		{at: now.Add(cooldown - time.Second), wantErr: ErrNoValidToken},
+		// Rejected tokens are retried after cooldown, starting from the current one.
+		{at: now.Add(cooldown), want: "c"},
+		{reject: "c", at: now.Add(cooldown), want: "a"},
+		{reject: "a", at: now.Add(cooldown), want: "b"},
+		{at: now.Add(2 * cooldown), want: "b"},
+	}
+	for i, step := range steps {
+		if step.reject != "" {
+			r.reject(step.reject, step.at)
+		}
+		got, err := r.get(step.at)
+		if !errors.Is(err, step.wantErr) {
+			t.Fatalf("step %d: get error = %v, want %v", i, err, step.wantErr)
+		}
+		if got != step.want {
+			t.Fatalf("step %d: get = %q, want %q", i, got, step.want)
+		}
+	}
+}
+
+func TestTokenRingRejectUnknownToken(t *testing.T) {
+	now := time.Unix(1700000000, 0)
+	r := newTokenRing([]string{"a"}, 0, time.Minute)
+	r.reject("b", now)
+	got, err := r.get(now)
+	if err != nil || got != "a" {
+		t.Fatalf("get = %q, %v; want %q", got, err, "a")
+	}
+}
+
+func TestTokenRingExpiryWarning(t *testing.T) {
+	core, logs := observer.New(zapcore.WarnLevel)
+	defer zap.ReplaceGlobals(zap.New(core))()
+	now := time.Unix(1700000000, 0)
+	soon := newExpiringJWT(now.Add(time.Hour))
+	later := newExpiringJWT(now.Add(48 * time.Hour))
+	r := newTokenRing([]string{soon, later}, 24*time.Hour, time.Minute)
+	// warnings counts warnings about token expiry.
+	warnings := func() int {
+		return logs.FilterMessageSnippet("expires at").Len()
+	}
+	for i := 0; i < 3; i++ {
+		_, err := r.get(now)
+		if err != nil {
+			t.Fatalf("get: %v", err)
+		}
+	}
+	if got := warnings(); got != 1 {
+		t.Fatalf("got %d expiry warnings for token expiring soon, want 1", got)
+	}
+	// The next token is far from expiry, so there is nothing to warn about.
+	r.reject(soon, now)
+	got, err := r.get(now)
+	if err != nil || got != later {
+		t.Fatalf("get = %q, %v; want the next token", got, err)
+	}
+	if got := warnings(); got != 1 {
+		t.Fatalf("got %d expiry warnings after swap, want 1", got)
+	}
+	// Warning is logged once the token gets close to expiry.
+	for i := 0; i < 3; i++ {
+		_, err = r.get(now.Add(25 * time.Hour))
+		if err != nil {
+			t.Fatalf("get: %v", err)
+		}
+	}
+	if got := warnings(); got != 2 {
+		t.Fatalf("got %d expiry warnings, want 2", got)
+	}
+}
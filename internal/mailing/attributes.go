@@ -0,0 +1,71 @@
+package mailing
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/pkg/errors"
+
+	"mailing/internal/models"
+)
+
+// DeleteAttribute deletes definition of custom attribute and removes it from all clients.
+// Attribute can't be deleted while filter or text of unfinished mailing, filter of segment
+// or the latest version of template references it, otherwise they would fail or render it empty.
+func (m *MailingService) DeleteAttribute(ctx context.Context, name string) error {
+	uses := []string{}
+	mailings, err := m.Storage.GetMailings(ctx)
+	if err != nil {
+		return errors.Wrap(err, "get mailings")
+	}
+	for _, mailing := range mailings {
+		if mailing.Status != models.MailingStatusPending && mailing.Status != models.MailingStatusExecuting &&
+			mailing.Status != models.MailingStatusTesting {
+			continue
+		}
+		texts := mailing.Texts()
+		for _, text := range mailing.Variants {
+			texts = append(texts, text)
+		}
+		if mailing.Filter.References(name) || textsReference(texts, name) {
+			uses = append(uses, fmt.Sprintf("mailing %v", mailing.ID))
+		}
+	}
+	segments, err := m.Storage.GetSegments(ctx)
+	if err != nil {
+		return errors.Wrap(err, "get segments")
+	}
+	for _, segment := range segments {
+		if segment.Filter.References(name) {
+			uses = append(uses, fmt.Sprintf("segment %q", segment.Name))
+		}
+	}
+	templates, err := m.Storage.GetTemplates(ctx)
+	if err != nil {
+		return errors.Wrap(err, "get templates")
+	}
+	for _, template := range templates {
+		if textAttributes(template.Text)[name] {
+			uses = append(uses, fmt.Sprintf("template %q", template.Name))
+		}
+	}
+	if len(uses) > 0 {
+		return errors.Wrapf(models.ErrAttributeInUse, "%s is referenced by %s", name, strings.Join(uses, ", "))
+	}
+	err = m.Storage.DeleteAttributeDefinition(ctx, name)
+	if err != nil {
+		return errors.Wrap(err, "delete attribute definition")
+	}
+	return nil
+}
+
+// textsReference reports whether any of texts references given attribute.
+func textsReference(texts []string, name string) bool {
+	for _, text := range texts {
+		if textAttributes(text)[name] {
+			return true
+		}
+	}
+	return false
+}
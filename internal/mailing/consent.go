@@ -0,0 +1,133 @@
+package mailing
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/pkg/errors"
+
+	"mailing/internal/models"
+)
+
+const _consentTokenLength = 24
+
+// RequestConsent starts double opt-in of client: pending consent is saved and client is asked to confirm it
+// by replying with one of consent keywords or by following confirmation link.
+func (m *MailingService) RequestConsent(ctx context.Context, clientID int64, channel string) (*models.Consent, error) {
+	client, err := m.Storage.GetClientByID(ctx, clientID)
+	if err != nil {
+		return nil, errors.Wrap(err, "get client by id")
+	}
+	suppressed, err := m.Storage.IsClientSuppressed(ctx, client)
+	if err != nil {
+		return nil, errors.Wrap(err, "check suppression")
+	}
+	if suppressed {
+		return nil, models.ErrClientSuppressed
+	}
+	token, err := randomCode(_consentTokenLength)
+	if err != nil {
+		return nil, errors.Wrap(err, "generate consent token")
+	}
+	consent := &models.Consent{
+		ClientID: client.ID,
+		Channel:  channel,
+		Status:   models.ConsentStatusPending,
+		Source:   models.ConsentSourceDoubleOptIn,
+		Token:    token,
+	}
+	err = m.Storage.SaveConsent(ctx, consent)
+	if err != nil {
+		return nil, errors.Wrap(err, "save consent")
+	}
+	msg := newMessage(uuid.Nil, client.ID, 0)
+	msg.Text = m.consentRequestText(token)
+	err = m.sendStandalone(ctx, msg, client)
+	if err != nil {
+		return nil, err
+	}
+	return consent, nil
+}
+
+// consentRequestText returns text of double opt-in request with confirmation link if it is configured.
+func (m *MailingService) consentRequestText(token string) string {
+	if m.config == nil {
+		return ""
+	}
+	if m.config.ConsentConfirmURL == "" {
+		return m.config.ConsentRequestText
+	}
+	return m.config.ConsentRequestText + " " + m.config.ConsentConfirmURL + "/" + token
+}
+
+// ConfirmConsent confirms double opt-in by token of confirmation link, proof is saved with granted consent.
+func (m *MailingService) ConfirmConsent(ctx context.Context, token, proof string) (*models.Consent, error) {
+	pending, err := m.Storage.GetConsentByToken(ctx, token)
+	if err != nil {
+		return nil, errors.Wrap(err, "get consent by token")
+	}
+	return m.grantConsent(ctx, pending, proof)
+}
+
+// grantConsent saves granted consent in place of pending one,
+// unless pending consent was superseded by another record or expired.
+func (m *MailingService) grantConsent(ctx context.Context, pending *models.Consent, proof string) (*models.Consent, error) {
+	latest, err := m.Storage.GetLatestConsent(ctx, pending.ClientID, pending.Channel)
+	if err != nil {
+		return nil, errors.Wrap(err, "get latest consent")
+	}
+	if latest == nil || latest.ID != pending.ID || pending.Status != models.ConsentStatusPending {
+		return nil, models.ErrConsentNotPending
+	}
+	if m.config != nil && m.config.ConsentTimeout > 0 && time.Since(pending.CreatedAt) > m.config.ConsentTimeout {
+		return nil, models.ErrConsentExpired
+	}
+	consent := &models.Consent{
+		ClientID: pending.ClientID,
+		Channel:  pending.Channel,
+		Status:   models.ConsentStatusGranted,
+		Source:   models.ConsentSourceDoubleOptIn,
+		Proof:    proof,
+	}
+	err = m.Storage.SaveConsent(ctx, consent)
+	if err != nil {
+		return nil, errors.Wrap(err, "save consent")
+	}
+	return consent, nil
+}
+
+// consentByReply handles client's reply to double opt-in request:
+// consent keyword confirms pending consent, opt-out revokes consent.
+func (m *MailingService) consentByReply(ctx context.Context, msg *models.InboundMessage) error {
+	proof := fmt.Sprintf("inbound message %d", msg.ID)
+	if msg.Stop {
+		err := m.Storage.SaveConsent(ctx, &models.Consent{
+			ClientID: *msg.ClientID,
+			Channel:  models.ConsentChannelSMS,
+			Status:   models.ConsentStatusRevoked,
+			Source:   models.SuppressionSourceInbound,
+			Proof:    proof,
+		})
+		if err != nil {
+			return errors.Wrap(err, "save consent")
+		}
+		return nil
+	}
+	if m.config == nil || !matchesKeyword(msg.Text, m.config.ConsentKeywords) {
+		return nil
+	}
+	latest, err := m.Storage.GetLatestConsent(ctx, *msg.ClientID, models.ConsentChannelSMS)
+	if err != nil {
+		return errors.Wrap(err, "get latest consent")
+	}
+	if latest == nil || latest.Status != models.ConsentStatusPending {
+		return nil
+	}
+	_, err = m.grantConsent(ctx, latest, proof)
+	if errors.Is(err, models.ErrConsentExpired) {
+		return nil
+	}
+	return err
+}
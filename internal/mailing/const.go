@@ -1,5 +1,13 @@
 package mailing
 
+import "time"
+
 // _sendRetry should be in config, parsed from json, as it is not a sensitive info
 // but for the sake of simplicity it is defined here.
 const _sendRetry = 3
+
+// _audiencePollInterval is how often dynamic mailing looks for newly matched clients.
+const _audiencePollInterval = 30 * time.Second
+
+// _pricesCacheTTL is how long price table is cached for standalone messages.
+const _pricesCacheTTL = time.Minute
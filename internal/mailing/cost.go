@@ -0,0 +1,108 @@
+package mailing
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/pkg/errors"
+	"go.uber.org/zap"
+
+	"mailing/internal/models"
+)
+
+// ErrBudgetExceeded is returned when mailing is stopped, because the next message would exceed it's budget.
+var ErrBudgetExceeded = errors.New("mailing budget exceeded")
+
+// costs prices messages of mailing's run and keeps track of mailing's budget.
+type costs struct {
+	prices   []*models.Price
+	provider string
+	budget   int64
+	mu       sync.Mutex
+	spent    int64
+}
+
+// newCosts loads price table and, if mailing has budget, the cost of messages sent in previous runs.
+// Mailing with budget can't run without prices, otherwise failure to load them is only logged.
+func (m *MailingService) newCosts(ctx context.Context, mailing *models.Mailing) (*costs, error) {
+	c := &costs{
+		budget:   mailing.Budget,
+		provider: m.provider(),
+	}
+	var err error
+	c.prices, err = m.Storage.GetPrices(ctx)
+	if err != nil {
+		if c.budget > 0 {
+			return nil, errors.Wrap(err, "get prices")
+		}
+		zap.L().Error(fmt.Sprintf("FAIL: get prices\nMailing: %v; Error: %v", mailing.ID, err))
+	}
+	if c.budget > 0 {
+		c.spent, err = m.Storage.GetMailingCost(ctx, mailing.ID)
+		if err != nil {
+			return nil, errors.Wrap(err, "get mailing cost")
+		}
+	}
+	return c, nil
+}
+
+// of returns the cost of message sent to client: it's segments times the price for client's operator.
+func (c *costs) of(msg *models.Message, client *models.Client) int64 {
+	return int64(msg.Segments) * models.FindPrice(c.prices, client.PhoneOperator, c.provider)
+}
+
+// spend reserves cost in budget, reporting false if it doesn't fit.
+func (c *costs) spend(cost int64) bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.budget > 0 && c.spent+cost > c.budget {
+		return false
+	}
+	c.spent += cost
+	return true
+}
+
+// refund returns cost of message, that wasn't sent, to budget.
+func (c *costs) refund(cost int64) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.spent -= cost
+}
+
+// provider returns the name of SMS provider prices are chosen for.
+func (m *MailingService) provider() string {
+	if m.config == nil {
+		return ""
+	}
+	return m.config.Provider
+}
+
+// price sets the cost of standalone message sent to client, prices that can't be loaded are logged and ignored.
+func (m *MailingService) price(ctx context.Context, msg *models.Message, client *models.Client) {
+	prices, err := m.cachedPrices(ctx)
+	if err != nil {
+		zap.L().Error(fmt.Sprintf("FAIL: get prices\nClient: %d; Error: %v", client.ID, err))
+	}
+	msg.Cost = int64(msg.Segments) * models.FindPrice(prices, client.PhoneOperator, m.provider())
+}
+
+// cachedPrices returns price table loaded at most _pricesCacheTTL ago, so that it isn't loaded for every standalone message.
+func (m *MailingService) cachedPrices(ctx context.Context) ([]*models.Price, error) {
+	m.mu.Lock()
+	if time.Since(m.pricesLoadedAt) < _pricesCacheTTL {
+		prices := m.prices
+		m.mu.Unlock()
+		return prices, nil
+	}
+	m.mu.Unlock()
+	prices, err := m.Storage.GetPrices(ctx)
+	if err != nil {
+		return nil, err
+	}
+	m.mu.Lock()
+	m.prices, m.pricesLoadedAt = prices, time.Now()
+	m.mu.Unlock()
+	return prices, nil
+}
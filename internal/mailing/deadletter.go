@@ -0,0 +1,251 @@
+package mailing
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/pkg/errors"
+	"go.uber.org/zap"
+
+	"mailing/internal/models"
+)
+
+// GetDeadLetters returns messages, that exhausted all send retries, of given mailing or of all mailings if mailingID is nil.
+func (m *MailingService) GetDeadLetters(ctx context.Context, mailingID *uuid.UUID) ([]*models.Message, error) {
+	messages, err := m.Storage.GetFailedMessages(ctx, mailingID)
+	if err != nil {
+		return nil, errors.Wrap(err, "get failed messages")
+	}
+	return messages, nil
+}
+
+// ReplayDeadLetters resends failed messages selected by replay, reusing their mailing's text.
+// Messages are resent as new ones: they expire after mailing's TTL from now, their cost is counted against mailing's budget
+// and frequency caps apply to them. Outcomes of resent messages are added to stats of the runs they were sent in.
+// Messages are not resent after mailing's end time, unless it is overridden by replay,
+// or to deleted and suppressed clients and to clients without consent if mailing requires it.
+// Messages of mailing with follow-up run in progress are not resent, and follow-up run can't start during replay.
+// Resending isn't canceled along with ctx, it is limited only by end time.
+func (m *MailingService) ReplayDeadLetters(ctx context.Context, replay *models.DeadLetterReplay) (*models.ReplayResult, error) {
+	l := zap.L()
+	messages := []*models.Message{}
+	if len(replay.MessageIDs) > 0 {
+		selected, err := m.Storage.GetMessagesByIDs(ctx, replay.MessageIDs)
+		if err != nil {
+			return nil, errors.Wrap(err, "get messages by ids")
+		}
+		messages = append(messages, selected...)
+	}
+	if replay.MailingID != nil {
+		failed, err := m.Storage.GetFailedMessages(ctx, replay.MailingID)
+		if err != nil {
+			return nil, errors.Wrap(err, "get failed messages")
+		}
+		messages = append(messages, failed...)
+	}
+
+	result := &models.ReplayResult{}
+	mailings := map[uuid.UUID]*models.Mailing{}
+	texts := map[uuid.UUID]*localizer{}
+	budgets := map[uuid.UUID]*costs{}
+	// busy are mailings with follow-up run in progress, guarded are mailings this replay keeps from starting one.
+	busy := map[uuid.UUID]bool{}
+	guarded := []uuid.UUID{}
+	defer func() {
+		m.mu.Lock()
+		for _, id := range guarded {
+			delete(m.resending, id)
+		}
+		m.mu.Unlock()
+	}()
+	type runKey struct {
+		mailingID uuid.UUID
+		run       int
+	}
+	// stats are changes made by replay to stats of mailings' runs.
+	stats := map[runKey]*models.MailingStats{}
+	attributes, err := m.Storage.GetAttributeDefinitions(ctx)
+	if err != nil {
+		return nil, errors.Wrap(err, "get attribute definitions")
+	}
+	seen := map[int64]bool{}
+	mu := sync.Mutex{}
+	wg := &sync.WaitGroup{}
+	// Messages being resent are waited for even if replay fails.
+	var failure error
+	for _, msg := range messages {
+		if seen[msg.ID] || msg.Status != models.SendStatusFailed {
+			continue
+		}
+		seen[msg.ID] = true
+		// Standalone messages don't store their text, so there is nothing to resend.
+		if msg.MailingID == uuid.Nil {
+			l.Info(fmt.Sprintf("Omitting replay of standalone message %d", msg.ID))
+			result.Skipped++
+			continue
+		}
+		mailing, ok := mailings[msg.MailingID]
+		if !ok {
+			var err error
+			mailing, err = m.Storage.GetMailingByID(ctx, msg.MailingID)
+			if err != nil {
+				failure = errors.Wrap(err, "get mailing by id")
+				break
+			}
+			mailings[msg.MailingID] = mailing
+			m.mu.Lock()
+			busy[mailing.ID] = m.resending[mailing.ID]
+			m.resending[mailing.ID] = true
+			m.mu.Unlock()
+			if !busy[mailing.ID] {
+				guarded = append(guarded, mailing.ID)
+			}
+			budgets[mailing.ID], err = m.newCosts(ctx, mailing)
+			if err != nil {
+				failure = err
+				break
+			}
+			texts[mailing.ID] = newLocalizer(mailing, attributes)
+		}
+		if busy[mailing.ID] {
+			l.Info(fmt.Sprintf("Omitting replay of message %d, follow-up run of mailing %v is in progress", msg.ID, mailing.ID))
+			result.Skipped++
+			continue
+		}
+		endTime := mailing.EndTime
+		if !replay.EndTime.IsZero() {
+			endTime = replay.EndTime
+		}
+		if time.Now().After(endTime) {
+			l.Info(fmt.Sprintf("Omitting replay of message %d of mailing %v", msg.ID, mailing.ID))
+			result.Skipped++
+			continue
+		}
+		client, err := m.Storage.GetClientByID(ctx, msg.ClientID)
+		if errors.Is(err, models.ErrClientNotFound) {
+			l.Info(fmt.Sprintf("Omitting replay of message %d of deleted client %d", msg.ID, msg.ClientID))
+			result.Skipped++
+			continue
+		}
+		if err != nil {
+			failure = errors.Wrap(err, "get client by id")
+			break
+		}
+		suppressed, err := m.Storage.IsClientSuppressed(ctx, client)
+		if err != nil {
+			failure = errors.Wrap(err, "check suppression")
+			break
+		}
+		if suppressed {
+			l.Info(fmt.Sprintf("Omitting replay of message %d of suppressed client %d", msg.ID, client.ID))
+			result.Skipped++
+			continue
+		}
+		if mailing.RequireConsent {
+			consent, err := m.Storage.GetLatestConsent(ctx, client.ID, models.ConsentChannelSMS)
+			if err != nil {
+				failure = errors.Wrap(err, "get latest consent")
+				break
+			}
+			if consent == nil || consent.Status != models.ConsentStatusGranted {
+				l.Info(fmt.Sprintf("Omitting replay of message %d of client %d without consent", msg.ID, client.ID))
+				result.Skipped++
+				continue
+			}
+		}
+		skips, err := m.frequencyCaps(ctx, []*models.Client{client})
+		if err != nil {
+			failure = errors.Wrap(err, "apply frequency caps")
+			break
+		}
+		if reason, ok := skips[client.ID]; ok {
+			l.Info(fmt.Sprintf("Omitting replay of message %d of client %d: %s", msg.ID, client.ID, reason))
+			result.Skipped++
+			continue
+		}
+		// Message is resent with the same text, messages saved before texts were stored are rendered again.
+		text := msg.Text
+		if text == "" {
+			text, _, err = texts[msg.MailingID].render(client)
+			if err != nil {
+				l.Info(fmt.Sprintf("Omitting replay of message %d, text can't be rendered\nClient: %d; Error: %v", msg.ID, client.ID, err))
+				result.Skipped++
+				continue
+			}
+		}
+		budget := budgets[mailing.ID]
+		msg.Segments = models.AnalyzeText(text).Segments
+		msg.Cost = budget.of(msg, client)
+		if !budget.spend(msg.Cost) {
+			l.Info(fmt.Sprintf("Omitting replay of message %d, budget of mailing %v is spent", msg.ID, mailing.ID))
+			result.Skipped++
+			continue
+		}
+		msg.TimeStamp = time.Now()
+		msg.ExpiresAt = nil
+		if mailing.TTL > 0 {
+			expiresAt := msg.TimeStamp.Add(mailing.TTL)
+			msg.ExpiresAt = &expiresAt
+		}
+		// Message is marked pending before it is resent, so that it isn't resent twice.
+		requeued, err := m.Storage.RequeueMessage(ctx, msg)
+		if err != nil {
+			budget.refund(msg.Cost)
+			failure = errors.Wrap(err, "requeue message")
+			break
+		}
+		if !requeued {
+			budget.refund(msg.Cost)
+			l.Info(fmt.Sprintf("Omitting replay of message %d, it is being resent already", msg.ID))
+			result.Skipped++
+			continue
+		}
+		result.Replayed++
+		wg.Add(1)
+		go func(msg *models.Message, client *models.Client, text string, endTime time.Time, budget *costs) {
+			defer wg.Done()
+			ctx, cancel := context.WithDeadline(context.WithoutCancel(ctx), endTime)
+			defer cancel()
+			err := m.deliver(ctx, msg, client.PhoneNumber, text)
+			if err != nil {
+				// Message, that wasn't sent, costs nothing.
+				budget.refund(msg.Cost)
+			}
+			mu.Lock()
+			defer mu.Unlock()
+			key := runKey{mailingID: msg.MailingID, run: msg.Run}
+			runStats, ok := stats[key]
+			if !ok {
+				runStats = &models.MailingStats{ID: msg.MailingID, Run: msg.Run, StartTime: time.Now()}
+				stats[key] = runStats
+			}
+			if errors.Is(err, ErrMessageExpired) {
+				result.Expired++
+				runStats.Fails--
+				runStats.Expired++
+				return
+			}
+			if err != nil {
+				result.Failed++
+				return
+			}
+			result.Succeeded++
+			runStats.Fails--
+			runStats.Cost += msg.Cost
+		}(msg, client, text, endTime, budget)
+	}
+	wg.Wait()
+	for _, runStats := range stats {
+		err := m.Storage.SaveStats(context.WithoutCancel(ctx), runStats)
+		if err != nil {
+			l.Error(fmt.Sprintf("FAIL: save stats of replay\nMailing: %v; Run: %d; Error: %v", runStats.ID, runStats.Run, err))
+		}
+	}
+	if failure != nil {
+		return nil, failure
+	}
+	return result, nil
+}
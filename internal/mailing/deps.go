@@ -2,6 +2,7 @@ package mailing
 
 import (
 	"context"
+	"time"
 
 	"mailing/internal/models"
 
@@ -16,15 +17,55 @@ type Storage interface {
 	GetClients(ctx context.Context) ([]*models.Client, error)
 	// GetClientByID returns client by id.
 	GetClientByID(ctx context.Context, id int64) (*models.Client, error)
+	// GetClientByPhoneNumber returns client by phone number, the oldest one if several clients share it.
+	GetClientByPhoneNumber(ctx context.Context, phoneNumber int64) (*models.Client, error)
 	// SaveClient saves client with all his atributes in Storage.
 	SaveClient(ctx context.Context, client *models.Client) error
 	// UpdateClient applies given update to client from storage by given id.
 	UpdateClient(ctx context.Context, id int64, update *models.ClientUpdate) error
 	// DeleteClient deletes client from storage by given id.
 	DeleteClient(ctx context.Context, id int64) error
+	// GetTags returns names of all tags.
+	GetTags(ctx context.Context) ([]string, error)
+	// UpdateTags adds and removes tags of given clients.
+	UpdateTags(ctx context.Context, update *models.TagsUpdate) error
+	// GetAttributeDefinitions returns definitions of all custom client's attributes.
+	GetAttributeDefinitions(ctx context.Context) ([]*models.AttributeDefinition, error)
+	// SaveAttributeDefinition saves definition of custom attribute, definition with the same name is replaced.
+	SaveAttributeDefinition(ctx context.Context, definition *models.AttributeDefinition) error
+	// DeleteAttributeDefinition deletes definition of custom attribute and removes it from all clients.
+	DeleteAttributeDefinition(ctx context.Context, name string) error
+	// GetSegments returns all segments.
+	GetSegments(ctx context.Context) ([]*models.Segment, error)
+	// GetSegmentByID returns segment by id.
+	GetSegmentByID(ctx context.Context, id int64) (*models.Segment, error)
+	// SaveSegment saves segment in Storage.
+	SaveSegment(ctx context.Context, segment *models.Segment) error
+	// UpdateSegment applies given update to segment from storage by given id.
+	UpdateSegment(ctx context.Context, id int64, update *models.SegmentUpdate) error
+	// DeleteSegment deletes segment from storage by given id.
+	// Segment referenced by mailings can't be deleted.
+	DeleteSegment(ctx context.Context, id int64) error
+	// GetTemplates returns all templates with their latest versions.
+	GetTemplates(ctx context.Context) ([]*models.Template, error)
+	// GetTemplateByID returns template with it's latest version by id.
+	GetTemplateByID(ctx context.Context, id int64) (*models.Template, error)
+	// GetTemplateVersions returns all versions of template, the latest first.
+	GetTemplateVersions(ctx context.Context, id int64) ([]*models.TemplateVersion, error)
+	// GetTemplateVersion returns given version of template, version 0 means the latest one.
+	GetTemplateVersion(ctx context.Context, id int64, version int) (*models.TemplateVersion, error)
+	// SaveTemplate saves template with it's text as the first version.
+	SaveTemplate(ctx context.Context, template *models.Template) error
+	// AddTemplateVersion saves text as the next version of template.
+	AddTemplateVersion(ctx context.Context, id int64, text string) (*models.TemplateVersion, error)
+	// DeleteTemplate deletes template with all it's versions, mailings keep their copies of text.
+	DeleteTemplate(ctx context.Context, id int64) error
 	// GetMailings return all mailings.
 	GetMailings(ctx context.Context) ([]*models.Mailing, error)
+	// GetMailingByID returns mailing by id.
+	GetMailingByID(ctx context.Context, id uuid.UUID) (*models.Mailing, error)
 	// SaveMailing saves mailing with all it's atributes in Storage.
+	// Audience of snapshot mailing is resolved and saved along with it.
 	SaveMailing(ctx context.Context, mailing *models.Mailing) error
 	// UpdateMailing applies given update to mailing from storage by given id.
 	UpdateMailing(ctx context.Context, id uuid.UUID, update *models.MailingUpdate) error
@@ -32,20 +73,95 @@ type Storage interface {
 	DeleteMailing(ctx context.Context, id uuid.UUID) error
 	// MarkMailing marks mailing status as given one.
 	MarkMailing(ctx context.Context, mailing *models.Mailing, status models.MailingStatus) error
-	// GetPendingMailings returns all mailings that were not yet started.
+	// GetPendingMailings returns all mailings that were not yet started and A/B tested mailings due for rollout.
 	GetPendingMailings(ctx context.Context) ([]*models.Mailing, error)
-	// SaveMessage saves message in Storage and returns it's id.
+	// SaveMessage saves message in Storage along with it's links and returns it's id.
 	SaveMessage(ctx context.Context, msg *models.Message) (int64, error)
+	// ClickLink counts click on short link against it's message and returns URL to redirect to.
+	ClickLink(ctx context.Context, code string) (string, error)
 	// MarkMessage marks message status as given one.
 	MarkMessage(ctx context.Context, msg *models.Message, status models.SendStatus) error
+	// MarkMessageFailed marks message status as failed and saves the error it failed with.
+	MarkMessageFailed(ctx context.Context, msg *models.Message, lastErr string) error
+	// RequeueMessage marks failed message as pending to resend it with it's new time stamp, expiry, segments and cost.
+	// It reports false if message is no longer failed, e.g. if it is being resent already.
+	RequeueMessage(ctx context.Context, msg *models.Message) (bool, error)
+	// GetMessagesByIDs returns messages by given ids.
+	GetMessagesByIDs(ctx context.Context, ids []int64) ([]*models.Message, error)
+	// GetFailedMessages returns failed messages of given mailing or of all mailings if mailingID is nil.
+	GetFailedMessages(ctx context.Context, mailingID *uuid.UUID) ([]*models.Message, error)
 	// CommonStatistic returns common statistic for mailings.
 	CommonStatistic(ctx context.Context) ([]*models.MailingStats, error)
 	// DetailedStatistic returns detailed statistic for given mailing.
 	DetailedStatistic(ctx context.Context, mailingID uuid.UUID) (*models.DetailedMailingStats, error)
-	// GetClientsForMailing gets clients which satisfy mailing's filter.
+	// GetClientsForMailing gets clients which satisfy mailing's filter and belong to mailing's segment.
+	// Clients of snapshot mailing are taken from it's saved audience instead. Suppressed clients are excluded.
 	GetClientsForMailing(ctx context.Context, mailing *models.Mailing) ([]*models.Client, error)
-	// SaveStats saves stats of executed mailing.
+	// GetNewClientsForMailing gets clients which satisfy mailing's filter and belong to mailing's segment,
+	// but have no message in the mailing yet and aren't held out. Suppressed clients are excluded.
+	GetNewClientsForMailing(ctx context.Context, mailing *models.Mailing) ([]*models.Client, error)
+	// GetMailingAudience returns ids of clients in saved audience of snapshot mailing.
+	GetMailingAudience(ctx context.Context, mailingID uuid.UUID) ([]int64, error)
+	// SaveMailingHoldout adds given clients to mailing's holdout group, clients already in it are ignored.
+	SaveMailingHoldout(ctx context.Context, mailingID uuid.UUID, clientIDs []int64) error
+	// GetMailingHoldout returns ids of clients in mailing's holdout group.
+	GetMailingHoldout(ctx context.Context, mailingID uuid.UUID) ([]int64, error)
+	// GetClientsByFilter gets clients which satisfy filter ordered by id, limit 0 means no limit.
+	GetClientsByFilter(ctx context.Context, filter *models.Filter, limit, offset int) ([]*models.Client, error)
+	// CountClientsByFilter returns the amount of clients which satisfy filter.
+	CountClientsByFilter(ctx context.Context, filter *models.Filter) (int, error)
+	// GetFailedClients gets clients whose message in given mailing failed and was never sent successfully.
+	// Suppressed clients are excluded, as well as clients without consent if mailing requires it.
+	GetFailedClients(ctx context.Context, mailing *models.Mailing) ([]*models.Client, error)
+	// GetLastRun returns the number of the last run of given mailing.
+	GetLastRun(ctx context.Context, mailingID uuid.UUID) (int, error)
+	// SaveStats saves stats of executed mailing, stats of the run already saved are added up with them.
 	SaveStats(ctx context.Context, mailingStats *models.MailingStats) error
+	// GetSuppressions returns suppression list, the newest entries first.
+	GetSuppressions(ctx context.Context) ([]*models.Suppression, error)
+	// SaveSuppression adds entry to suppression list.
+	// Client or phone number, that is already suppressed, can't be added again.
+	SaveSuppression(ctx context.Context, suppression *models.Suppression) error
+	// DeleteSuppression deletes entry from suppression list by given id, ErrSuppressionNotFound is returned if there is none.
+	DeleteSuppression(ctx context.Context, id int64) error
+	// IsClientSuppressed reports whether client matches suppression list by id or phone number.
+	IsClientSuppressed(ctx context.Context, client *models.Client) (bool, error)
+	// SaveInboundMessage saves message received from client's phone in Storage.
+	SaveInboundMessage(ctx context.Context, msg *models.InboundMessage) error
+	// GetLastMessageID returns id of the last message sent to client, nil if client has no messages.
+	GetLastMessageID(ctx context.Context, clientID int64) (*int64, error)
+	// GetConversation returns messages sent to and received from client in chronological order.
+	// Limit and offset select the latest messages, offset counts from the newest one.
+	GetConversation(ctx context.Context, clientID int64, limit, offset int) ([]*models.ConversationEntry, error)
+	// GetConsents returns consent records of client, the newest first.
+	GetConsents(ctx context.Context, clientID int64) ([]*models.Consent, error)
+	// GetLatestConsent returns the current consent record of client in channel, nil if there is none.
+	GetLatestConsent(ctx context.Context, clientID int64, channel string) (*models.Consent, error)
+	// GetConsentByToken returns pending consent record by token of confirmation link.
+	GetConsentByToken(ctx context.Context, token string) (*models.Consent, error)
+	// SaveConsent saves consent record in Storage.
+	SaveConsent(ctx context.Context, consent *models.Consent) error
+	// GetRecentMailingMessages returns times of mailing messages sent or being sent to given clients
+	// since given time, the newest first, by client's id.
+	GetRecentMailingMessages(ctx context.Context, clientIDs []int64, since time.Time) (map[int64][]time.Time, error)
+	// GetVariantStats returns stats of A/B test variants of mailing in test stage, ordered by variant.
+	GetVariantStats(ctx context.Context, mailingID uuid.UUID) ([]models.VariantStats, error)
+	// ScheduleABTestRollout saves the time of rollout of mailing's A/B test winner and marks mailing as testing,
+	// so that it is returned by GetPendingMailings once the rollout is due.
+	ScheduleABTestRollout(ctx context.Context, mailingID uuid.UUID, rolloutAt time.Time) error
+	// SaveABTestWinner saves the winning variant of mailing's A/B test.
+	SaveABTestWinner(ctx context.Context, mailingID uuid.UUID, winner int) error
+	// CountSuppressedClients returns the amount of clients in mailing's audience, that are suppressed.
+	CountSuppressedClients(ctx context.Context, mailing *models.Mailing) (int, error)
+	// GetPrices returns price table ordered by phone operator and provider.
+	GetPrices(ctx context.Context) ([]*models.Price, error)
+	// SavePrice adds price to price table or changes price of the same phone operator and provider.
+	SavePrice(ctx context.Context, price *models.Price) error
+	// DeletePrice deletes price from price table by given id.
+	DeletePrice(ctx context.Context, id int64) error
+	// GetMailingCost returns the total cost of mailing's messages in all runs.
+	// Skipped messages and messages, that failed or expired, cost nothing.
+	GetMailingCost(ctx context.Context, mailingID uuid.UUID) (int64, error)
 }
 
 // MessageSender is an interface to send messages.
@@ -53,3 +169,9 @@ type MessageSender interface {
 	// Send sends message via MessageSender.
 	Send(ctx context.Context, msgID int64, clientPhone int64, text string) error
 }
+
+// RateLimited is implemented by MessageSender, that limits the rate of sending.
+type RateLimited interface {
+	// RateLimit returns the maximum amount of messages sent per second, 0 means no limit.
+	RateLimit() int
+}
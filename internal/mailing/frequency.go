@@ -0,0 +1,69 @@
+package mailing
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/pkg/errors"
+	"go.uber.org/zap"
+
+	"mailing/internal/models"
+)
+
+// frequencyCaps returns reasons to skip clients, that got too many mailing messages recently, by client's id.
+// Messages are counted when run starts, so concurrent mailings may exceed caps.
+func (m *MailingService) frequencyCaps(ctx context.Context, clients []*models.Client) (map[int64]string, error) {
+	if m.config == nil || len(clients) == 0 || (m.config.FrequencyCap <= 0 && m.config.MinMessageGap <= 0) {
+		return map[int64]string{}, nil
+	}
+	var window time.Duration
+	if m.config.FrequencyCap > 0 {
+		window = m.config.FrequencyCapWindow
+	}
+	ids := make([]int64, 0, len(clients))
+	for _, client := range clients {
+		ids = append(ids, client.ID)
+	}
+	now := time.Now()
+	recent, err := m.Storage.GetRecentMailingMessages(ctx, ids, now.Add(-max(window, m.config.MinMessageGap)))
+	if err != nil {
+		return nil, errors.Wrap(err, "get recent mailing messages")
+	}
+	reasons := map[int64]string{}
+	for clientID, times := range recent {
+		// Times are ordered from the newest.
+		if gap := now.Sub(times[0]); gap < m.config.MinMessageGap {
+			reasons[clientID] = fmt.Sprintf("previous message was sent %v ago, minimum gap is %v",
+				gap.Round(time.Second), m.config.MinMessageGap)
+			continue
+		}
+		if m.config.FrequencyCap <= 0 {
+			continue
+		}
+		count := 0
+		for _, t := range times {
+			if now.Sub(t) < window {
+				count++
+			}
+		}
+		if count >= m.config.FrequencyCap {
+			reasons[clientID] = fmt.Sprintf("%d messages were sent in the last %v, cap is %d",
+				count, window, m.config.FrequencyCap)
+		}
+	}
+	return reasons, nil
+}
+
+// skip saves message of mailing's run, that isn't sent to client for given reason, counting it in stats.
+func (m *MailingService) skip(ctx context.Context, msg *models.Message, reason string, stats *models.MailingStats) {
+	l := zap.L()
+	msg.Run = stats.Run
+	msg.Status = models.SendStatusSkipped
+	msg.SkipReason = reason
+	stats.Skipped++
+	_, err := m.Storage.SaveMessage(ctx, msg)
+	if err != nil {
+		l.Error(fmt.Sprintf("FAIL: could not save skipped message in storage\nClient: %d; Error: %v", msg.ClientID, err))
+	}
+}
@@ -0,0 +1,36 @@
+package mailing
+
+import (
+	"context"
+	"fmt"
+
+	"go.uber.org/zap"
+
+	"mailing/internal/models"
+)
+
+// holdOut returns clients, that don't belong to mailing's holdout group, and saves the rest as held out.
+// Held out clients are never sent to, even if their membership couldn't be saved.
+func (m *MailingService) holdOut(ctx context.Context, mailing *models.Mailing, clients []*models.Client) []*models.Client {
+	l := zap.L()
+	if mailing.HoldoutPercent <= 0 {
+		return clients
+	}
+	recipients := make([]*models.Client, 0, len(clients))
+	heldOut := []int64{}
+	for _, client := range clients {
+		if mailing.HeldOut(client.ID) {
+			heldOut = append(heldOut, client.ID)
+			continue
+		}
+		recipients = append(recipients, client)
+	}
+	if len(heldOut) == 0 {
+		return recipients
+	}
+	err := m.Storage.SaveMailingHoldout(ctx, mailing.ID, heldOut)
+	if err != nil {
+		l.Error(fmt.Sprintf("FAIL: save holdout group\nMailing: %v; Error: %v", mailing.ID, err))
+	}
+	return recipients
+}
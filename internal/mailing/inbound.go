@@ -0,0 +1,106 @@
+package mailing
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+	"unicode"
+
+	"github.com/google/uuid"
+	"github.com/pkg/errors"
+	"go.uber.org/zap"
+
+	"mailing/internal/models"
+)
+
+// ReceiveMessage saves message received from client's phone, linking it to the client and the last message sent to them.
+// Reply matching one of STOP keywords suppresses the phone number, revokes consent and is confirmed with a message.
+// Reply matching one of consent keywords confirms pending double opt-in.
+func (m *MailingService) ReceiveMessage(ctx context.Context, msg *models.InboundMessage) error {
+	l := zap.L()
+	if msg.ReceivedAt.IsZero() {
+		msg.ReceivedAt = time.Now().UTC()
+	}
+	client, err := m.Storage.GetClientByPhoneNumber(ctx, msg.PhoneNumber)
+	if err != nil && !errors.Is(err, models.ErrClientNotFound) {
+		return errors.Wrap(err, "get client by phone number")
+	}
+	if client != nil {
+		msg.ClientID = &client.ID
+		msg.ReplyTo, err = m.Storage.GetLastMessageID(ctx, client.ID)
+		if err != nil {
+			return errors.Wrap(err, "get last message id")
+		}
+	}
+	msg.Stop = m.config != nil && matchesKeyword(msg.Text, m.config.StopKeywords)
+	err = m.Storage.SaveInboundMessage(ctx, msg)
+	if err != nil {
+		return errors.Wrap(err, "save inbound message")
+	}
+	if client != nil {
+		err = m.consentByReply(ctx, msg)
+		if err != nil {
+			return errors.Wrap(err, "update consent")
+		}
+	}
+	if !msg.Stop {
+		return nil
+	}
+	err = m.Storage.SaveSuppression(ctx, &models.Suppression{
+		ClientID:    msg.ClientID,
+		PhoneNumber: &msg.PhoneNumber,
+		Reason:      fmt.Sprintf("replied %q", msg.Text),
+		Source:      models.SuppressionSourceInbound,
+	})
+	if err != nil && !errors.Is(err, models.ErrAlreadySuppressed) {
+		return errors.Wrap(err, "save suppression")
+	}
+	// Confirmation is sent only to known clients, since every message belongs to client.
+	if client == nil || m.config == nil || m.config.StopConfirmation == "" {
+		return nil
+	}
+	confirmation := newMessage(uuid.Nil, client.ID, 0)
+	confirmation.Text = m.config.StopConfirmation
+	err = m.sendStandalone(ctx, confirmation, client)
+	if err != nil {
+		// Client is suppressed anyway, so failed confirmation doesn't fail the reply.
+		l.Warn(fmt.Sprintf("Couldn't confirm opt-out\nClient: %d; Error: %v", client.ID, err))
+	}
+	return nil
+}
+
+// matchesKeyword reports whether text is one of keywords, ignoring case and surrounding punctuation.
+func matchesKeyword(text string, keywords []string) bool {
+	text = strings.TrimFunc(text, func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+	})
+	for _, keyword := range keywords {
+		if strings.EqualFold(text, keyword) {
+			return true
+		}
+	}
+	return false
+}
+
+// Reply sends agent's reply in conversation with client. Unlike SendMessage, text is sent verbatim.
+func (m *MailingService) Reply(ctx context.Context, clientID int64, text string) (*models.Message, error) {
+	client, err := m.Storage.GetClientByID(ctx, clientID)
+	if err != nil {
+		return nil, errors.Wrap(err, "get client by id")
+	}
+	suppressed, err := m.Storage.IsClientSuppressed(ctx, client)
+	if err != nil {
+		return nil, errors.Wrap(err, "check suppression")
+	}
+	if suppressed {
+		return nil, models.ErrClientSuppressed
+	}
+	msg := newMessage(uuid.Nil, client.ID, 0)
+	msg.Text = text
+	err = m.sendStandalone(ctx, msg, client)
+	if err != nil {
+		return nil, err
+	}
+	return msg, nil
+}
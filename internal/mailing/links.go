@@ -0,0 +1,68 @@
+package mailing
+
+import (
+	"crypto/rand"
+	"regexp"
+	"strings"
+
+	"github.com/pkg/errors"
+
+	"mailing/internal/models"
+)
+
+const (
+	_linkCodeLength = 8
+	_codeAlphabet   = "0123456789abcdefghijklmnopqrstuvwxyzABCDEFGHIJKLMNOPQRSTUVWXYZ"
+)
+
+var urlRegexp = regexp.MustCompile(`https?://[^\s<>"]+`)
+
+// shortenLinks replaces URLs in text with short links, text is returned as is if short links are disabled.
+// Punctuation at the end of URL is considered a part of the sentence, not of the URL.
+func (m *MailingService) shortenLinks(text string) (string, []*models.Link, error) {
+	if m.config == nil || m.config.ShortLinkBaseURL == "" {
+		return text, nil, nil
+	}
+	links := []*models.Link{}
+	byURL := map[string]*models.Link{}
+	var err error
+	text = urlRegexp.ReplaceAllStringFunc(text, func(match string) string {
+		url := strings.TrimRight(match, ".,;:!?)'")
+		link, ok := byURL[url]
+		if !ok {
+			var code string
+			code, err = randomCode(_linkCodeLength)
+			if err != nil {
+				return match
+			}
+			link = &models.Link{Code: code, URL: url}
+			byURL[url] = link
+			links = append(links, link)
+		}
+		return m.config.ShortLinkBaseURL + "/" + link.Code + match[len(url):]
+	})
+	if err != nil {
+		return "", nil, errors.Wrap(err, "generate link code")
+	}
+	return text, links, nil
+}
+
+// randomCode returns random alphanumeric code of given length, e.g. of short link.
+// Random bytes, that don't fit into a whole number of alphabets, are rejected, so that all characters are equally likely.
+func randomCode(length int) (string, error) {
+	limit := byte(256 / len(_codeAlphabet) * len(_codeAlphabet))
+	code := make([]byte, 0, length)
+	b := make([]byte, length)
+	for len(code) < length {
+		_, err := rand.Read(b)
+		if err != nil {
+			return "", err
+		}
+		for _, r := range b {
+			if r < limit && len(code) < length {
+				code = append(code, _codeAlphabet[int(r)%len(_codeAlphabet)])
+			}
+		}
+	}
+	return string(code), nil
+}
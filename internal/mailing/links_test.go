@@ -0,0 +1,123 @@
+package mailing
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+
+	"mailing/internal/config"
+)
+
+func TestShortenLinks(t *testing.T) {
+	const base = "https://s.example.com/l"
+	tests := []struct {
+		name string
+		text string
+		// want is expected text with short links replaced by {0}, {1}, ... in order of links.
+		want string
+		urls []string
+	}{
+		{name: "no links", text: "Hello!", want: "Hello!", urls: []string{}},
+		{name: "link", text: "Visit https://example.com/sale", want: "Visit {0}", urls: []string{"https://example.com/sale"}},
+		{name: "http link", text: "http://example.com", want: "{0}", urls: []string{"http://example.com"}},
+		{
+			name: "trailing punctuation",
+			text: "Sale at https://example.com/sale. Hurry!",
+			want: "Sale at {0}. Hurry!",
+			urls: []string{"https://example.com/sale"},
+		},
+		{
+			name: "link in parentheses",
+			text: "Sale (https://example.com/sale?a=1&b=2)!",
+			want: "Sale ({0})!",
+			urls: []string{"https://example.com/sale?a=1&b=2"},
+		},
+		{
+			name: "repeated link",
+			text: "https://example.com/a https://example.com/b https://example.com/a",
+			want: "{0} {1} {0}",
+			urls: []string{"https://example.com/a", "https://example.com/b"},
+		},
+		{
+			name: "same link with different punctuation",
+			text: "https://example.com/a, https://example.com/a!",
+			want: "{0}, {0}!",
+			urls: []string{"https://example.com/a"},
+		},
+		{
+			name: "link in quotes",
+			text: `Open "https://example.com/a"`,
+			want: `Open "{0}"`,
+			urls: []string{"https://example.com/a"},
+		},
+		{name: "not a link", text: "ftp://example.com example.com", want: "ftp://example.com example.com", urls: []string{}},
+	}
+	m := &MailingService{config: &config.MailingConfig{ShortLinkBaseURL: base}}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			text, links, err := m.shortenLinks(tt.text)
+			if err != nil {
+				t.Fatalf("shortenLinks: %v", err)
+			}
+			if len(links) != len(tt.urls) {
+				t.Fatalf("got %d links, want %d", len(links), len(tt.urls))
+			}
+			want := tt.want
+			for i, link := range links {
+				if link.URL != tt.urls[i] {
+					t.Errorf("link %d URL = %q, want %q", i, link.URL, tt.urls[i])
+				}
+				if len(link.Code) != _linkCodeLength {
+					t.Errorf("link %d code %q has length %d, want %d", i, link.Code, len(link.Code), _linkCodeLength)
+				}
+				want = strings.ReplaceAll(want, fmt.Sprintf("{%d}", i), base+"/"+link.Code)
+			}
+			if text != want {
+				t.Errorf("text = %q, want %q", text, want)
+			}
+		})
+	}
+}
+
+func TestShortenLinksDisabled(t *testing.T) {
+	const text = "Visit https://example.com/sale"
+	for _, m := range []*MailingService{{}, {config: &config.MailingConfig{}}} {
+		got, links, err := m.shortenLinks(text)
+		if err != nil || got != text || links != nil {
+			t.Errorf("shortenLinks = %q, %v, %v; want text as is", got, links, err)
+		}
+	}
+}
+
+func TestRandomCode(t *testing.T) {
+	const samples = 62 * 1000
+	counts := map[rune]int{}
+	for i := 0; i < samples/_linkCodeLength; i++ {
+		code, err := randomCode(_linkCodeLength)
+		if err != nil {
+			t.Fatalf("randomCode: %v", err)
+		}
+		if len(code) != _linkCodeLength {
+			t.Fatalf("code %q has length %d, want %d", code, len(code), _linkCodeLength)
+		}
+		for _, r := range code {
+			if !strings.ContainsRune(_codeAlphabet, r) {
+				t.Fatalf("code %q has character %q out of alphabet", code, r)
+			}
+			counts[r]++
+		}
+	}
+	// With modulo bias the first 8 characters of alphabet would be about 25% more frequent than the rest.
+	first, rest := 0, 0
+	for i, r := range _codeAlphabet {
+		if i < 8 {
+			first += counts[r]
+		} else {
+			rest += counts[r]
+		}
+	}
+	ratio := float64(first) / 8 / (float64(rest) / float64(len(_codeAlphabet)-8))
+	if ratio > 1.1 || ratio < 0.9 {
+		t.Errorf("the first characters of alphabet are %.2f times as frequent as the rest, want about 1", ratio)
+	}
+}
@@ -10,21 +10,33 @@ import (
 	"github.com/pkg/errors"
 	"go.uber.org/zap"
 
+	"mailing/internal/config"
 	"mailing/internal/models"
 )
 
+// ErrMessageExpired is returned when message became stale before it was sent.
+var ErrMessageExpired = errors.New("message expired")
+
 // MailingService is a service with business logic.
 type MailingService struct {
 	mu            sync.Mutex
 	Storage       Storage
 	MessageSender MessageSender
+	config        *config.MailingConfig
+	// resending are mailings with follow-up run in progress.
+	resending map[uuid.UUID]bool
+	// prices is price table cached for standalone messages.
+	prices         []*models.Price
+	pricesLoadedAt time.Time
 }
 
 // New creates new MailingService.
-func New(storage Storage, messageSender MessageSender) *MailingService {
+func New(storage Storage, messageSender MessageSender, config *config.MailingConfig) *MailingService {
 	return &MailingService{
 		Storage:       storage,
 		MessageSender: messageSender,
+		config:        config,
+		resending:     map[uuid.UUID]bool{},
 	}
 }
 
@@ -53,9 +65,14 @@ func (m *MailingService) StartFetchingMailings(ctx context.Context) error {
 						defer wg.Done()
 						if time.Now().After(mailing.EndTime) {
 							l.Warn(fmt.Sprintf("End time of mailing exceeds current time, omitting mailing...\nMailing: %v", mailing.ID))
-							err := m.Storage.MarkMailing(ctx, mailing, models.MailingStatusInvalid)
+							status := models.MailingStatusInvalid
+							if mailing.Status == models.MailingStatusTesting {
+								// A/B test was sent, but it's too late to roll out the winner.
+								status = models.MailingStatusCanceled
+							}
+							err := m.Storage.MarkMailing(ctx, mailing, status)
 							if err != nil {
-								l.Error(fmt.Sprintf("FAIL: mark mailing status as invalid\nMailing: %v; Error: %v", mailing.ID, err))
+								l.Error(fmt.Sprintf("FAIL: mark mailing status\nMailing: %v; Error: %v", mailing.ID, err))
 							}
 							return
 						}
@@ -82,104 +99,406 @@ func (m *MailingService) mail(ctx context.Context, mailing *models.Mailing) erro
 	if err != nil {
 		return errors.Wrap(err, "mark mailing status as executing")
 	}
-	clients, err := m.Storage.GetClientsForMailing(ctx, mailing)
+	if mailing.AudienceMode == models.AudienceModeDynamic {
+		_, err = m.runDynamic(ctx, mailing)
+	} else {
+		var clients []*models.Client
+		var suppressed int
+		clients, err = m.Storage.GetClientsForMailing(ctx, mailing)
+		if err != nil {
+			nestedErr := m.Storage.MarkMailing(ctx, mailing, models.MailingStatusFailed)
+			if nestedErr != nil {
+				l.Error(fmt.Sprintf("FAIL: mark mailing status as failed\nMailing: %v; Error: %v", mailing.ID, nestedErr))
+			}
+			return errors.Wrap(err, "get clients for mailing")
+		}
+		clients = mailing.Sample(clients, mailing.MaxRecipients)
+		suppressed, err = m.Storage.CountSuppressedClients(ctx, mailing)
+		if err != nil {
+			l.Error(fmt.Sprintf("FAIL: count suppressed clients\nMailing: %v; Error: %v", mailing.ID, err))
+		}
+		if mailing.ABTest != nil && mailing.ABTest.Winner == 0 {
+			var testing bool
+			testing, err = m.runABTest(ctx, mailing, clients, suppressed)
+			if err == nil && testing {
+				return nil
+			}
+		} else {
+			_, err = m.run(ctx, mailing, clients, 0, suppressed)
+		}
+	}
+	// Context may be already done, but the outcome still has to be saved.
+	saveCtx := context.WithoutCancel(ctx)
+	if errors.Is(err, ErrBudgetExceeded) {
+		l.Warn(fmt.Sprintf("Mailing stopped, budget of %d is spent\nMailing: %v", mailing.Budget, mailing.ID))
+		err = m.Storage.MarkMailing(saveCtx, mailing, models.MailingStatusBudgetExceeded)
+		if err != nil {
+			l.Error(fmt.Sprintf("FAIL: mark mailing as budget exceeded\nMailing: %v; Error: %v", mailing.ID, err))
+		}
+		return nil
+	}
+	if err != nil && ctx.Err() != nil {
+		err = m.Storage.MarkMailing(saveCtx, mailing, models.MailingStatusCanceled)
+		if err != nil {
+			l.Error(fmt.Sprintf("FAIL: mark mailing as canceled\nMailing: %v; Error: %v", mailing.ID, err))
+		}
+		return ctx.Err()
+	}
 	if err != nil {
-		nestedErr := m.Storage.MarkMailing(ctx, mailing, models.MailingStatusFailed)
+		nestedErr := m.Storage.MarkMailing(saveCtx, mailing, models.MailingStatusFailed)
 		if nestedErr != nil {
 			l.Error(fmt.Sprintf("FAIL: mark mailing status as failed\nMailing: %v; Error: %v", mailing.ID, nestedErr))
 		}
-		return errors.Wrap(err, "get clients for mailing")
+		return errors.Wrap(err, "run mailing")
+	}
+	err = m.Storage.MarkMailing(saveCtx, mailing, models.MailingStatusDone)
+	if err != nil {
+		l.Error(fmt.Sprintf("FAIL: mark mailing as done\nMailing: %v; Error: %v", mailing.ID, err))
 	}
-	fails := 0
+	return nil
+}
+
+// run sends mailing's text to given clients and saves stats of the run along with the amount of suppressed clients.
+// The first run of mailing is numbered 0, follow-up runs are numbered from 1.
+func (m *MailingService) run(ctx context.Context, mailing *models.Mailing, clients []*models.Client, run, suppressed int) (*models.MailingStats, error) {
+	l := zap.L()
 	wg := &sync.WaitGroup{}
-	start := time.Now()
-	for count, client := range clients {
+	stats := &models.MailingStats{
+		ID:         mailing.ID,
+		Run:        run,
+		Matches:    len(clients),
+		Suppressed: suppressed,
+		StartTime:  time.Now(),
+	}
+	attributes, err := m.Storage.GetAttributeDefinitions(ctx)
+	if err != nil {
+		l.Error(fmt.Sprintf("FAIL: get attribute definitions\nMailing: %v; Error: %v", mailing.ID, err))
+	}
+	texts := newVariantLocalizers(mailing, attributes)
+	costs, err := m.newCosts(ctx, mailing)
+	if err != nil {
+		return stats, err
+	}
+	recipients := m.holdOut(ctx, mailing, clients)
+	stats.HeldOut = len(clients) - len(recipients)
+	skips, err := m.frequencyCaps(ctx, recipients)
+	if err != nil {
+		l.Error(fmt.Sprintf("FAIL: apply frequency caps\nMailing: %v; Error: %v", mailing.ID, err))
+	}
+	for count, client := range recipients {
 		select {
 		// ctx.Done is called when context deadline is exceeded or if cancel() is called on parent context.
 		case <-ctx.Done():
 			wg.Wait()
-			err = m.Storage.MarkMailing(ctx, mailing, models.MailingStatusCanceled)
-			if err != nil {
-				l.Error(fmt.Sprintf("FAIL: mark mailing as canceled\nMailing: %v; Error: %v", mailing.ID, err))
-			}
-			err = m.Storage.SaveStats(ctx, &models.MailingStats{
-				ID:            mailing.ID,
-				Fails:         fails,
-				StartTime:     start,
-				TimeExecuting: time.Since(start),
-				Sent:          count + 1,
-				Matches:       len(clients),
-			})
+			stats.Sent = count + 1 - stats.Skipped
+			stats.TimeExecuting = time.Since(stats.StartTime)
+			err := m.Storage.SaveStats(context.WithoutCancel(ctx), stats)
 			if err != nil {
 				l.Error("Couldn't save stats of mailing")
 			}
-			return ctx.Err()
+			return stats, ctx.Err()
 		default:
-			wg.Add(1)
-			go func(client *models.Client) {
-				defer wg.Done()
-				msg := newMessage(mailing.ID, client.ID)
-				msg.ID, err = m.Storage.SaveMessage(ctx, msg)
-				if err != nil {
-					l.Error(fmt.Sprintf("FAIL: could not save message in storage\nMessage: %d; Client: %d; Error: %v\n",
-						msg.ID, client.ID, err))
-					m.mu.Lock()
-					fails++
-					m.mu.Unlock()
-					return
-				}
-				for try := 0; try < _sendRetry; try++ {
-					err = m.MessageSender.Send(ctx, msg.ID, client.PhoneNumber, mailing.Text)
-					if err != nil {
-						l.Info(fmt.Sprintf("Send failed, retrying in 1s...\nMessage: %d; Client: %d; Error: %v\n", msg.ID, client.ID, err))
-						time.Sleep(time.Second)
-						continue
-					}
-					break
-				}
-				if err != nil {
-					l.Error(fmt.Sprintf("FAIL: could not send message in %d tries\nMessage: %d; Client: %d; Error: %v",
-						_sendRetry, msg.ID, client.ID, err))
-					m.mu.Lock()
-					fails++
-					m.mu.Unlock()
-					err = m.Storage.MarkMessage(ctx, msg, models.SendStatusFailed)
-					if err != nil {
-						l.Error(fmt.Sprintf("FAIL: mark message as failed\nMessage: %d; Client: %d; Error: %v", msg.ID, client.ID, err))
-					}
-					return
-				}
-				err = m.Storage.MarkMessage(ctx, msg, models.SendStatusSuccess)
+			msg := newMessage(mailing.ID, client.ID, mailing.TTL)
+			msg.Variant = variantFor(mailing, client.ID)
+			// Winner of A/B test is rolled out in the same run as the test.
+			msg.Rollout = run == 0 && mailing.ABTest != nil && mailing.ABTest.Winner > 0
+			text, locale, err := texts[max(msg.Variant, 1)-1].render(client)
+			msg.Text, msg.Locale = text, locale
+			if err != nil {
+				m.skip(ctx, msg, err.Error(), stats)
+			} else if reason, ok := skips[client.ID]; ok {
+				m.skip(ctx, msg, reason, stats)
+			} else if !m.send(ctx, wg, msg, client, costs, stats) {
+				wg.Wait()
+				stats.Sent = count - stats.Skipped
+				stats.TimeExecuting = time.Since(stats.StartTime)
+				err := m.Storage.SaveStats(ctx, stats)
 				if err != nil {
-					l.Error(fmt.Sprintf("FAIL: mark message as success\nMessage: %d; Client: %d; Error: %v", msg.ID, client.ID, err))
+					l.Error("Couldn't save stats of mailing")
 				}
-			}(client)
+				return stats, ErrBudgetExceeded
+			}
 		}
 	}
 	wg.Wait()
-	err = m.Storage.MarkMailing(ctx, mailing, models.MailingStatusDone)
+	stats.Sent = len(recipients) - stats.Skipped
+	stats.TimeExecuting = time.Since(stats.StartTime)
+	err = m.Storage.SaveStats(ctx, stats)
 	if err != nil {
-		l.Error(fmt.Sprintf("FAIL: mark mailing as done\nMailing: %v; Error: %v", mailing.ID, err))
+		l.Error("Couldn't save stats of mailing")
 	}
-	err = m.Storage.SaveStats(ctx, &models.MailingStats{
-		ID:            mailing.ID,
-		Fails:         fails,
-		StartTime:     start,
-		TimeExecuting: time.Since(start),
-		Sent:          len(clients),
-		Matches:       len(clients),
-	})
+	return stats, nil
+}
+
+// runDynamic sends mailing's text to matching clients until mailing's end time,
+// polling for clients matched after the start, and saves stats of the run.
+// Reaching the end time is a normal completion of dynamic mailing.
+func (m *MailingService) runDynamic(ctx context.Context, mailing *models.Mailing) (*models.MailingStats, error) {
+	l := zap.L()
+	wg := &sync.WaitGroup{}
+	stats := &models.MailingStats{
+		ID:        mailing.ID,
+		StartTime: time.Now(),
+	}
+	attributes, err := m.Storage.GetAttributeDefinitions(ctx)
 	if err != nil {
-		l.Error("Couldn't save stats of mailing")
+		l.Error(fmt.Sprintf("FAIL: get attribute definitions\nMailing: %v; Error: %v", mailing.ID, err))
+	}
+	text := newLocalizer(mailing, attributes)
+	costs, err := m.newCosts(ctx, mailing)
+	if err != nil {
+		return stats, err
+	}
+	// finish waits for messages being sent and saves stats of the run.
+	finish := func() {
+		wg.Wait()
+		stats.TimeExecuting = time.Since(stats.StartTime)
+		saveCtx := context.WithoutCancel(ctx)
+		stats.Suppressed, err = m.Storage.CountSuppressedClients(saveCtx, mailing)
+		if err != nil {
+			l.Error(fmt.Sprintf("FAIL: count suppressed clients\nMailing: %v; Error: %v", mailing.ID, err))
+		}
+		err = m.Storage.SaveStats(saveCtx, stats)
+		if err != nil {
+			l.Error("Couldn't save stats of mailing")
+		}
+	}
+	exceeded := false
+	// Messages are saved asynchronously, so clients already sent to may be matched by the next poll.
+	sent := map[int64]bool{}
+	ticker := time.NewTicker(_audiencePollInterval)
+	defer ticker.Stop()
+	for {
+		clients, err := m.Storage.GetNewClientsForMailing(ctx, mailing)
+		if err != nil && ctx.Err() == nil {
+			l.Error(fmt.Sprintf("FAIL: get new clients for mailing\nMailing: %v; Error: %v", mailing.ID, err))
+		}
+		fresh := []*models.Client{}
+		for _, client := range clients {
+			if !sent[client.ID] {
+				sent[client.ID] = true
+				fresh = append(fresh, client)
+			}
+		}
+		// Clients left out of sample are remembered as sent, so they are never reconsidered.
+		if mailing.MaxRecipients > 0 && stats.Matches >= mailing.MaxRecipients {
+			fresh = nil
+		} else if mailing.MaxRecipients > 0 {
+			fresh = mailing.Sample(fresh, mailing.MaxRecipients-stats.Matches)
+		} else {
+			fresh = mailing.Sample(fresh, 0)
+		}
+		stats.Matches += len(fresh)
+		recipients := m.holdOut(ctx, mailing, fresh)
+		stats.HeldOut += len(fresh) - len(recipients)
+		skips, err := m.frequencyCaps(ctx, recipients)
+		if err != nil && ctx.Err() == nil {
+			l.Error(fmt.Sprintf("FAIL: apply frequency caps\nMailing: %v; Error: %v", mailing.ID, err))
+		}
+		for _, client := range recipients {
+			if ctx.Err() != nil {
+				break
+			}
+			msg := newMessage(mailing.ID, client.ID, mailing.TTL)
+			msg.Text, msg.Locale, err = text.render(client)
+			if err != nil {
+				m.skip(ctx, msg, err.Error(), stats)
+				continue
+			}
+			if reason, ok := skips[client.ID]; ok {
+				m.skip(ctx, msg, reason, stats)
+				continue
+			}
+			if !m.send(ctx, wg, msg, client, costs, stats) {
+				exceeded = true
+				break
+			}
+			stats.Sent++
+		}
+		if exceeded {
+			finish()
+			return stats, ErrBudgetExceeded
+		}
+		select {
+		case <-ctx.Done():
+			finish()
+			if errors.Is(ctx.Err(), context.DeadlineExceeded) {
+				return stats, nil
+			}
+			return stats, ctx.Err()
+		case <-ticker.C:
+		}
+	}
+}
+
+// send saves message of mailing's run and delivers it to client's phone in background, counting failures in stats.
+// Message's cost is added to stats, message that doesn't fit into mailing's budget isn't sent and send reports false.
+func (m *MailingService) send(ctx context.Context, wg *sync.WaitGroup, msg *models.Message, client *models.Client,
+	costs *costs, stats *models.MailingStats) bool {
+	l := zap.L()
+	msg.Run = stats.Run
+	var err error
+	msg.Text, msg.Links, err = m.shortenLinks(msg.Text)
+	if err != nil {
+		l.Error(fmt.Sprintf("FAIL: could not shorten links\nClient: %d; Error: %v\n", msg.ClientID, err))
+		m.mu.Lock()
+		stats.Fails++
+		m.mu.Unlock()
+		return true
+	}
+	msg.Segments = models.AnalyzeText(msg.Text).Segments
+	msg.Cost = costs.of(msg, client)
+	if !costs.spend(msg.Cost) {
+		return false
+	}
+	m.mu.Lock()
+	stats.Cost += msg.Cost
+	m.mu.Unlock()
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		var err error
+		msg.ID, err = m.Storage.SaveMessage(ctx, msg)
+		if err != nil {
+			l.Error(fmt.Sprintf("FAIL: could not save message in storage\nMessage: %d; Client: %d; Error: %v\n",
+				msg.ID, msg.ClientID, err))
+			costs.refund(msg.Cost)
+			m.mu.Lock()
+			stats.Fails++
+			stats.Cost -= msg.Cost
+			m.mu.Unlock()
+			return
+		}
+		err = m.deliver(ctx, msg, client.PhoneNumber, msg.Text)
+		if err != nil {
+			// Message, that wasn't sent, costs nothing.
+			costs.refund(msg.Cost)
+			m.mu.Lock()
+			if errors.Is(err, ErrMessageExpired) {
+				stats.Expired++
+			} else {
+				stats.Fails++
+			}
+			stats.Cost -= msg.Cost
+			m.mu.Unlock()
+		}
+	}()
+	return true
+}
+
+// deliver sends message to client's phone, retrying on failure, and marks message according to the result.
+// Message is dropped with ErrMessageExpired as soon as it becomes stale,
+// including while it waits for rate limit or for its batch.
+func (m *MailingService) deliver(ctx context.Context, msg *models.Message, clientPhone int64, text string) error {
+	l := zap.L()
+	var err error
+	for try := 0; try < _sendRetry; try++ {
+		if msg.Expired(time.Now()) {
+			return m.expire(ctx, msg)
+		}
+		sendCtx, cancel := ctx, context.CancelFunc(func() {})
+		if msg.ExpiresAt != nil {
+			sendCtx, cancel = context.WithDeadline(ctx, *msg.ExpiresAt)
+		}
+		err = m.MessageSender.Send(sendCtx, msg.ID, clientPhone, text)
+		// Deadline of message, not of ctx, means that message became stale while being sent.
+		expired := err != nil && ctx.Err() == nil && sendCtx.Err() != nil
+		cancel()
+		if expired {
+			return m.expire(ctx, msg)
+		}
+		if err != nil {
+			l.Info(fmt.Sprintf("Send failed, retrying in 1s...\nMessage: %d; Client: %d; Error: %v\n", msg.ID, msg.ClientID, err))
+			time.Sleep(time.Second)
+			continue
+		}
+		break
+	}
+	// Message may become stale during the last retry.
+	if err != nil && msg.Expired(time.Now()) {
+		return m.expire(ctx, msg)
+	}
+	if err != nil {
+		l.Error(fmt.Sprintf("FAIL: could not send message in %d tries\nMessage: %d; Client: %d; Error: %v",
+			_sendRetry, msg.ID, msg.ClientID, err))
+		nestedErr := m.Storage.MarkMessageFailed(ctx, msg, err.Error())
+		if nestedErr != nil {
+			l.Error(fmt.Sprintf("FAIL: mark message as failed\nMessage: %d; Client: %d; Error: %v", msg.ID, msg.ClientID, nestedErr))
+		}
+		return err
+	}
+	err = m.Storage.MarkMessage(ctx, msg, models.SendStatusSuccess)
+	if err != nil {
+		l.Error(fmt.Sprintf("FAIL: mark message as success\nMessage: %d; Client: %d; Error: %v", msg.ID, msg.ClientID, err))
 	}
 	return nil
 }
 
-func newMessage(mailingID uuid.UUID, clientID int64) *models.Message {
-	return &models.Message{
+// expire marks message, that became stale before it was sent, as expired.
+func (m *MailingService) expire(ctx context.Context, msg *models.Message) error {
+	l := zap.L()
+	l.Info(fmt.Sprintf("Message expired, dropping...\nMessage: %d; Client: %d", msg.ID, msg.ClientID))
+	err := m.Storage.MarkMessage(ctx, msg, models.SendStatusExpired)
+	if err != nil {
+		l.Error(fmt.Sprintf("FAIL: mark message as expired\nMessage: %d; Client: %d; Error: %v", msg.ID, msg.ClientID, err))
+	}
+	return ErrMessageExpired
+}
+
+// SendMessage sends standalone message with given text to client, ttl of 0 means that message never expires.
+// Text is personalized for client the same way as mailing's text, text that can't be rendered is not sent.
+// Suppressed client is never sent to.
+func (m *MailingService) SendMessage(ctx context.Context, clientID int64, text string, ttl time.Duration) (*models.Message, error) {
+	client, err := m.Storage.GetClientByID(ctx, clientID)
+	if err != nil {
+		return nil, errors.Wrap(err, "get client by id")
+	}
+	suppressed, err := m.Storage.IsClientSuppressed(ctx, client)
+	if err != nil {
+		return nil, errors.Wrap(err, "check suppression")
+	}
+	if suppressed {
+		return nil, models.ErrClientSuppressed
+	}
+	attributes, err := m.Storage.GetAttributeDefinitions(ctx)
+	if err != nil {
+		return nil, errors.Wrap(err, "get attribute definitions")
+	}
+	msg := newMessage(uuid.Nil, client.ID, ttl)
+	msg.Text, err = newPersonalizer(text, attributes).render(client)
+	if err != nil {
+		return nil, err
+	}
+	return msg, m.sendStandalone(ctx, msg, client)
+}
+
+// sendStandalone saves standalone message and delivers it to client's phone.
+func (m *MailingService) sendStandalone(ctx context.Context, msg *models.Message, client *models.Client) error {
+	msg.Segments = models.AnalyzeText(msg.Text).Segments
+	m.price(ctx, msg, client)
+	var err error
+	msg.ID, err = m.Storage.SaveMessage(ctx, msg)
+	if err != nil {
+		return errors.Wrap(err, "save message")
+	}
+	err = m.deliver(ctx, msg, client.PhoneNumber, msg.Text)
+	if err != nil {
+		return errors.Wrap(err, "deliver message")
+	}
+	return nil
+}
+
+// newMessage returns pending message, ttl of 0 means that message never expires.
+func newMessage(mailingID uuid.UUID, clientID int64, ttl time.Duration) *models.Message {
+	msg := &models.Message{
 		TimeStamp: time.Now(),
 		Status:    models.SendStatusPending,
 		MailingID: mailingID,
 		ClientID:  clientID,
 	}
+	if ttl > 0 {
+		expiresAt := msg.TimeStamp.Add(ttl)
+		msg.ExpiresAt = &expiresAt
+	}
+	return msg
 }
@@ -0,0 +1,398 @@
+package mailing
+
+import (
+	"reflect"
+	"strings"
+	"text/template"
+	"text/template/parse"
+	"time"
+
+	"github.com/pkg/errors"
+	"go.uber.org/zap"
+
+	"mailing/internal/models"
+)
+
+// ErrTextNotRendered is returned when message text, that is a valid template, fails to render for client.
+var ErrTextNotRendered = errors.New("text can't be rendered")
+
+// messageData is data available to placeholders of message text, e.g. {{.Attr.name}}.
+type messageData struct {
+	// Tag is the first of client's tags in alphabetical order, empty if client has no tags.
+	Tag string
+	// Tags are all of client's tags.
+	Tags          []string
+	PhoneNumber   int64
+	PhoneOperator int
+	Timezone      string
+	// LocalTime is the time of rendering in client's timezone, e.g. {{.LocalTime.Format "15:04"}}.
+	LocalTime time.Time
+	// Attr are client's custom attributes, e.g. {{.Attr.city}}. Defined attributes client doesn't have are empty.
+	Attr map[string]any
+}
+
+// textFuncs are functions available in message text.
+var textFuncs = template.FuncMap{
+	// default returns fallback if value is empty, e.g. {{default "friend" .Attr.name}} or {{.Attr.name | default "friend"}}.
+	"default": func(fallback, value any) any {
+		if value == nil {
+			return fallback
+		}
+		if s, ok := value.(string); ok && s == "" {
+			return fallback
+		}
+		return value
+	},
+}
+
+// parseText parses message text as template.
+func parseText(text string) (*template.Template, error) {
+	return template.New("text").Funcs(textFuncs).Parse(text)
+}
+
+// ValidateText checks that message text is a valid template referencing only known variables and attributes.
+func ValidateText(text string, attributes []*models.AttributeDefinition) error {
+	tmpl, err := parseText(text)
+	if err != nil {
+		return errors.Wrap(err, "parse text")
+	}
+	defined := map[string]bool{}
+	for _, a := range attributes {
+		defined[a.Name] = true
+	}
+	for _, t := range tmpl.Templates() {
+		if t.Tree == nil {
+			continue
+		}
+		err = validateNode(t.Tree.Root, defined)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// messageDataType is the type fields referenced in message text are checked against.
+var messageDataType = reflect.TypeOf(messageData{})
+
+// validateNode checks fields referenced by template node against messageData.
+// Dot is rebound inside with and range, so fields relative to it are not checked there.
+func validateNode(node parse.Node, defined map[string]bool) error {
+	if node == nil || reflect.ValueOf(node).IsNil() {
+		return nil
+	}
+	switch n := node.(type) {
+	case *parse.ListNode:
+		for _, child := range n.Nodes {
+			err := validateNode(child, defined)
+			if err != nil {
+				return err
+			}
+		}
+	case *parse.ActionNode:
+		return validateNode(n.Pipe, defined)
+	case *parse.IfNode:
+		for _, child := range []parse.Node{n.Pipe, n.List, n.ElseList} {
+			err := validateNode(child, defined)
+			if err != nil {
+				return err
+			}
+		}
+	case *parse.WithNode:
+		err := validateNode(n.Pipe, defined)
+		if err != nil {
+			return err
+		}
+		return validateNode(n.ElseList, defined)
+	case *parse.RangeNode:
+		err := validateNode(n.Pipe, defined)
+		if err != nil {
+			return err
+		}
+		return validateNode(n.ElseList, defined)
+	case *parse.TemplateNode:
+		return validateNode(n.Pipe, defined)
+	case *parse.PipeNode:
+		for i, cmd := range n.Cmds {
+			for j, arg := range cmd.Args {
+				// The first operand of command is called with the rest of operands,
+				// and with the result of previous command if there is one.
+				args := 0
+				if j == 0 {
+					args = len(cmd.Args) - 1
+					if i > 0 {
+						args++
+					}
+				}
+				err := validateOperand(arg, defined, args)
+				if err != nil {
+					return err
+				}
+			}
+		}
+	default:
+		return validateOperand(node, defined, 0)
+	}
+	return nil
+}
+
+// validateOperand checks fields referenced by operand of command, that is called with given amount of arguments.
+func validateOperand(node parse.Node, defined map[string]bool, args int) error {
+	switch n := node.(type) {
+	case *parse.FieldNode:
+		return validateField(n.Ident, defined, args)
+	case *parse.VariableNode:
+		// $ is messageData, other variables are declared in text and are not checked.
+		if n.Ident[0] == "$" && len(n.Ident) > 1 {
+			return validateField(n.Ident[1:], defined, args)
+		}
+	case *parse.ChainNode:
+		// Fields of parenthesized field, e.g. (.LocalTime).Year, are checked as one field.
+		if pipe, ok := n.Node.(*parse.PipeNode); ok && len(pipe.Decl) == 0 && len(pipe.Cmds) == 1 && len(pipe.Cmds[0].Args) == 1 {
+			if field, ok := pipe.Cmds[0].Args[0].(*parse.FieldNode); ok {
+				return validateField(append(append([]string{}, field.Ident...), n.Field...), defined, args)
+			}
+		}
+		return validateNode(n.Node, defined)
+	case *parse.PipeNode:
+		return validateNode(n, defined)
+	}
+	return nil
+}
+
+// validateField checks that field can be evaluated on messageData the way template does it:
+// every element of the chain is an exported field or a method returning a value, and attribute is defined.
+// Method at the end of the chain is called with given amount of arguments, methods before it are called without.
+func validateField(ident []string, defined map[string]bool, args int) error {
+	typ := messageDataType
+	for i, name := range ident {
+		field := "." + strings.Join(ident[:i+1], ".")
+		n := 0
+		if i == len(ident)-1 {
+			n = args
+		}
+		if i == 1 && ident[0] == "Attr" {
+			if !defined[name] {
+				return errors.Errorf("unknown attribute %s", field)
+			}
+			if n > 0 {
+				return errors.Errorf("attribute %s can't be called with arguments", field)
+			}
+			typ = typ.Elem()
+			continue
+		}
+		if method, ok := typ.MethodByName(name); ok {
+			err := validateMethod(field, method.Type, n)
+			if err != nil {
+				return err
+			}
+			typ = method.Type.Out(0)
+			continue
+		}
+		if typ.Kind() == reflect.Pointer {
+			typ = typ.Elem()
+		}
+		if typ.Kind() != reflect.Struct {
+			if i == 0 {
+				return errors.Errorf("unknown variable %s", field)
+			}
+			return errors.Errorf("unknown field %s, %s has no fields", field, "."+strings.Join(ident[:i], "."))
+		}
+		f, ok := typ.FieldByName(name)
+		if !ok || !f.IsExported() {
+			if i == 0 {
+				return errors.Errorf("unknown variable %s", field)
+			}
+			return errors.Errorf("unknown field %s", field)
+		}
+		if n > 0 {
+			return errors.Errorf("%s is not a method and can't be called with arguments", field)
+		}
+		typ = f.Type
+	}
+	return nil
+}
+
+// validateMethod checks that method of given type, which includes receiver, can be called in template with given amount of arguments.
+func validateMethod(field string, method reflect.Type, args int) error {
+	params := method.NumIn() - 1
+	if method.IsVariadic() && args < params-1 || !method.IsVariadic() && args != params {
+		return errors.Errorf("method %s takes %d arguments, got %d", field, params, args)
+	}
+	errorType := reflect.TypeOf((*error)(nil)).Elem()
+	if method.NumOut() == 1 || method.NumOut() == 2 && method.Out(1) == errorType {
+		return nil
+	}
+	return errors.Errorf("method %s can't be called in text, it has to return a value and optionally an error", field)
+}
+
+// textAttributes returns names of attributes text references, e.g. as {{.Attr.city}} or {{index .Attr "city"}}.
+// Text that isn't a valid template references none.
+func textAttributes(text string) map[string]bool {
+	names := map[string]bool{}
+	tmpl, err := parseText(text)
+	if err != nil {
+		return names
+	}
+	for _, t := range tmpl.Templates() {
+		if t.Tree != nil {
+			collectAttributes(t.Tree.Root, names)
+		}
+	}
+	return names
+}
+
+// collectAttributes adds names of attributes referenced by template node to names.
+func collectAttributes(node parse.Node, names map[string]bool) {
+	if node == nil || reflect.ValueOf(node).IsNil() {
+		return
+	}
+	var children []parse.Node
+	switch n := node.(type) {
+	case *parse.ListNode:
+		children = n.Nodes
+	case *parse.ActionNode:
+		children = []parse.Node{n.Pipe}
+	case *parse.IfNode:
+		children = []parse.Node{n.Pipe, n.List, n.ElseList}
+	case *parse.WithNode:
+		children = []parse.Node{n.Pipe, n.List, n.ElseList}
+	case *parse.RangeNode:
+		children = []parse.Node{n.Pipe, n.List, n.ElseList}
+	case *parse.TemplateNode:
+		children = []parse.Node{n.Pipe}
+	case *parse.PipeNode:
+		for _, cmd := range n.Cmds {
+			children = append(children, cmd)
+		}
+	case *parse.CommandNode:
+		children = n.Args
+		// Attribute may be taken from attributes by name, e.g. {{index .Attr "city"}}.
+		if len(n.Args) >= 3 {
+			ident, isIdent := n.Args[0].(*parse.IdentifierNode)
+			field, isField := n.Args[1].(*parse.FieldNode)
+			name, isString := n.Args[2].(*parse.StringNode)
+			if isIdent && ident.Ident == "index" && isField && len(field.Ident) == 1 && field.Ident[0] == "Attr" && isString {
+				names[name.Text] = true
+			}
+		}
+	case *parse.ChainNode:
+		children = []parse.Node{n.Node}
+	case *parse.FieldNode:
+		if len(n.Ident) > 1 && n.Ident[0] == "Attr" {
+			names[n.Ident[1]] = true
+		}
+	case *parse.VariableNode:
+		if len(n.Ident) > 2 && n.Ident[0] == "$" && n.Ident[1] == "Attr" {
+			names[n.Ident[2]] = true
+		}
+	}
+	for _, child := range children {
+		collectAttributes(child, names)
+	}
+}
+
+// personalizer renders message text for each client. It is not safe for concurrent use.
+type personalizer struct {
+	text       string
+	tmpl       *template.Template
+	attributes []*models.AttributeDefinition
+	locations  map[string]*time.Location
+}
+
+// newPersonalizer parses message text, text that isn't a valid template is rendered verbatim.
+// Text is validated when mailing is saved, so it may only be invalid for mailings saved before.
+func newPersonalizer(text string, attributes []*models.AttributeDefinition) *personalizer {
+	p := &personalizer{text: text, attributes: attributes, locations: map[string]*time.Location{}}
+	if !strings.Contains(text, "{{") {
+		return p
+	}
+	tmpl, err := parseText(text)
+	if err != nil {
+		zap.L().Warn("Message text is not a valid template, sending it verbatim: " + err.Error())
+		return p
+	}
+	p.tmpl = tmpl
+	return p
+}
+
+// render returns text personalized for given client. Text is never sent unrendered,
+// so ErrTextNotRendered is returned if template fails, e.g. if attribute was redefined after text was validated.
+func (p *personalizer) render(client *models.Client) (string, error) {
+	if p.tmpl == nil {
+		return p.text, nil
+	}
+	data := messageData{
+		Tags:          client.Tags,
+		PhoneNumber:   client.PhoneNumber,
+		PhoneOperator: client.PhoneOperator,
+		Timezone:      client.Timezone,
+		LocalTime:     time.Now().In(p.location(client.Timezone)),
+		Attr:          map[string]any{},
+	}
+	if len(client.Tags) > 0 {
+		data.Tag = client.Tags[0]
+	}
+	for _, a := range p.attributes {
+		data.Attr[a.Name] = ""
+	}
+	for name, v := range client.Attributes {
+		data.Attr[name] = v
+	}
+	b := &strings.Builder{}
+	err := p.tmpl.Execute(b, data)
+	if err != nil {
+		return "", errors.Wrap(ErrTextNotRendered, err.Error())
+	}
+	return b.String(), nil
+}
+
+// location returns location of client's timezone, UTC if timezone is unknown.
+func (p *personalizer) location(timezone string) *time.Location {
+	loc, ok := p.locations[timezone]
+	if !ok {
+		var err error
+		loc, err = time.LoadLocation(timezone)
+		if err != nil {
+			loc = time.UTC
+		}
+		p.locations[timezone] = loc
+	}
+	return loc
+}
+
+// localizer renders the text variant matching client's locale. It is not safe for concurrent use.
+type localizer struct {
+	text     *personalizer
+	variants map[string]*personalizer
+	locales  []string
+}
+
+// newLocalizer parses mailing's text and its localized variants.
+func newLocalizer(mailing *models.Mailing, attributes []*models.AttributeDefinition) *localizer {
+	l := &localizer{
+		text:     newPersonalizer(mailing.Text, attributes),
+		variants: map[string]*personalizer{},
+	}
+	for locale, text := range mailing.Variants {
+		l.variants[locale] = newPersonalizer(text, attributes)
+		l.locales = append(l.locales, locale)
+	}
+	return l
+}
+
+// locale returns locale of the variant for given client, empty if default text is used.
+func (l *localizer) locale(client *models.Client) string {
+	return models.MatchLocale(client.Locale, l.locales)
+}
+
+// render returns text personalized for given client and locale of the variant, empty if default text is used.
+func (l *localizer) render(client *models.Client) (string, string, error) {
+	locale := l.locale(client)
+	if locale == "" {
+		text, err := l.text.render(client)
+		return text, "", err
+	}
+	text, err := l.variants[locale].render(client)
+	return text, locale, err
+}
@@ -0,0 +1,146 @@
+package mailing
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+
+	"mailing/internal/models"
+)
+
+func TestValidateText(t *testing.T) {
+	attributes := []*models.AttributeDefinition{
+		{Name: "name", Type: models.AttributeTypeString},
+	}
+	tests := []struct {
+		name    string
+		text    string
+		wantErr bool
+	}{
+		{name: "plain text", text: "Hello!"},
+		{name: "empty text", text: ""},
+		{name: "variable", text: "Your number is {{.PhoneNumber}}"},
+		{name: "defined attribute", text: "Hello, {{.Attr.name}}!"},
+		{name: "default function", text: `Hello, {{default "friend" .Attr.name}}!`},
+		{name: "default in pipeline", text: `Hello, {{.Attr.name | default "friend"}}!`},
+		{name: "method of variable", text: `It's {{.LocalTime.Format "15:04"}}`},
+		{name: "if", text: `{{if .Tag}}{{.Tag}}{{else}}{{.Timezone}}{{end}}`},
+		{name: "range rebinds dot", text: `{{range .Tags}}{{.}} {{end}}`},
+		{name: "with rebinds dot", text: `{{with .Attr}}{{.unknown}}{{end}}`},
+		{name: "unclosed action", text: "Hello, {{.Attr.name", wantErr: true},
+		{name: "unknown function", text: "{{upper .Tag}}", wantErr: true},
+		{name: "unknown variable", text: "{{.Name}}", wantErr: true},
+		{name: "undefined attribute", text: "{{.Attr.city}}", wantErr: true},
+		{name: "undefined attribute in if", text: "{{if .Attr.city}}!{{end}}", wantErr: true},
+		{name: "undefined attribute in else", text: "{{if .Tag}}{{else}}{{.Attr.city}}{{end}}", wantErr: true},
+		{name: "undefined attribute in range pipeline", text: "{{range .Attr.cities}}{{end}}", wantErr: true},
+		{name: "unknown variable in with else", text: "{{with .Tag}}{{else}}{{.Name}}{{end}}", wantErr: true},
+		{name: "unknown variable in defined template", text: `{{define "t"}}{{.Name}}{{end}}`, wantErr: true},
+		{name: "method chain", text: `{{.LocalTime.Location.String}}`},
+		{name: "parenthesized field", text: `{{(.LocalTime).Year}}`},
+		{name: "root variable", text: `{{$.Attr.name}} {{$.LocalTime.Year}}`},
+		{name: "declared variable", text: `{{$t := .Tag}}{{$t}}`},
+		{name: "field of number", text: "{{.PhoneNumber.Foo}}", wantErr: true},
+		{name: "field of string", text: "{{.Tag.Foo}}", wantErr: true},
+		{name: "field of list", text: "{{.Tags.Foo}}", wantErr: true},
+		{name: "field of attribute", text: "{{.Attr.name.x}}", wantErr: true},
+		{name: "field of undefined attribute", text: "{{.Attr.city.x}}", wantErr: true},
+		{name: "unknown method", text: "{{.LocalTime.Bogus}}", wantErr: true},
+		{name: "unexported field", text: "{{.LocalTime.wall}}", wantErr: true},
+		{name: "method without arguments", text: "{{.LocalTime.Format}}", wantErr: true},
+		{name: "method with extra arguments", text: `{{.LocalTime.Year "x"}}`, wantErr: true},
+		{name: "method with piped argument", text: `{{"15:04" | .LocalTime.Format}}`},
+		{name: "method returning many values", text: "{{.LocalTime.Date}}", wantErr: true},
+		{name: "field with arguments", text: `{{.Tag "x"}}`, wantErr: true},
+		{name: "attribute with arguments", text: `{{.Attr.name "x"}}`, wantErr: true},
+		{name: "field of parenthesized field", text: `{{(.LocalTime).Bogus}}`, wantErr: true},
+		{name: "field of root variable", text: `{{$.PhoneNumber.Foo}}`, wantErr: true},
+		{name: "invalid field in function argument", text: `{{default .Tag.Foo .Tag}}`, wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := ValidateText(tt.text, attributes)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("ValidateText(%q) error = %v, wantErr %v", tt.text, err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestPersonalizerRender(t *testing.T) {
+	attributes := []*models.AttributeDefinition{
+		{Name: "name", Type: models.AttributeTypeString},
+	}
+	client := &models.Client{
+		PhoneNumber: 79990000001,
+		Tags:        []string{"vip"},
+		Attributes:  map[string]any{"name": "Ann"},
+	}
+	tests := []struct {
+		name    string
+		text    string
+		client  *models.Client
+		want    string
+		wantErr bool
+	}{
+		{name: "plain text", text: "Hello!", client: client, want: "Hello!"},
+		{name: "invalid template is sent verbatim", text: "Hello, {{.Attr.name", client: client, want: "Hello, {{.Attr.name"},
+		{name: "attribute", text: "Hello, {{.Attr.name}}!", client: client, want: "Hello, Ann!"},
+		{name: "missing attribute", text: `Hello, {{default "friend" .Attr.name}}!`, client: &models.Client{}, want: "Hello, friend!"},
+		{name: "tag", text: "{{.Tag}} {{.PhoneNumber}}", client: client, want: "vip 79990000001"},
+		{name: "failed template", text: "{{index .Tags 1}}", client: client, wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := newPersonalizer(tt.text, attributes).render(tt.client)
+			if tt.wantErr {
+				if !errors.Is(err, ErrTextNotRendered) {
+					t.Fatalf("render error = %v, want %v", err, ErrTextNotRendered)
+				}
+				if got != "" {
+					t.Errorf("render = %q, want no text", got)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("render: %v", err)
+			}
+			if got != tt.want {
+				t.Errorf("render = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestTextAttributes(t *testing.T) {
+	tests := []struct {
+		name string
+		text string
+		want []string
+	}{
+		{name: "plain text", text: "Hello!"},
+		{name: "invalid template", text: "{{.Attr.city"},
+		{name: "field", text: "{{.Attr.city}} {{.Tag}}", want: []string{"city"}},
+		{name: "field of field", text: "{{.Attr.city.x}}", want: []string{"city"}},
+		{name: "root variable", text: "{{$.Attr.city}}", want: []string{"city"}},
+		{name: "index", text: `{{index .Attr "city"}}`, want: []string{"city"}},
+		{name: "function argument", text: `{{default .Attr.name .Attr.city}}`, want: []string{"city", "name"}},
+		{name: "pipeline", text: `{{.Attr.city | default "Moscow"}}`, want: []string{"city"}},
+		{name: "branches", text: `{{if .Attr.vip}}{{.Attr.name}}{{else}}{{.Attr.city}}{{end}}`, want: []string{"city", "name", "vip"}},
+		{name: "with and range bodies", text: `{{with .Tag}}{{$.Attr.name}}{{end}}{{range .Tags}}{{$.Attr.city}}{{end}}`, want: []string{"city", "name"}},
+		{name: "parenthesized", text: `{{(.Attr.city)}}`, want: []string{"city"}},
+		{name: "defined template", text: `{{define "t"}}{{.Attr.city}}{{end}}`, want: []string{"city"}},
+		{name: "attributes as a whole", text: "{{.Attr}}"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			want := map[string]bool{}
+			for _, name := range tt.want {
+				want[name] = true
+			}
+			if got := textAttributes(tt.text); !reflect.DeepEqual(got, want) {
+				t.Errorf("textAttributes(%q) = %v, want %v", tt.text, got, want)
+			}
+		})
+	}
+}
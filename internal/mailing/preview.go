@@ -0,0 +1,79 @@
+package mailing
+
+import (
+	"context"
+	"time"
+
+	"github.com/pkg/errors"
+
+	"mailing/internal/models"
+)
+
+// PreviewMailing returns audience and sample messages of mailing.
+// Nothing is saved and nothing is sent.
+func (m *MailingService) PreviewMailing(ctx context.Context, mailing *models.Mailing, samples int) (*models.MailingPreview, error) {
+	// Mailing is not saved, so there is no snapshot yet and audience is resolved as if mailing started now.
+	live := *mailing
+	live.AudienceMode = models.AudienceModeStart
+	clients, err := m.Storage.GetClientsForMailing(ctx, &live)
+	if err != nil {
+		return nil, errors.Wrap(err, "get clients for mailing")
+	}
+	matched := len(clients)
+	clients = mailing.Sample(clients, mailing.MaxRecipients)
+	suppressed, err := m.Storage.CountSuppressedClients(ctx, &live)
+	if err != nil {
+		return nil, errors.Wrap(err, "count suppressed clients")
+	}
+	attributes, err := m.Storage.GetAttributeDefinitions(ctx)
+	if err != nil {
+		return nil, errors.Wrap(err, "get attribute definitions")
+	}
+	text := newLocalizer(mailing, attributes)
+	preview := &models.MailingPreview{
+		Matches:         len(clients),
+		Suppressed:      suppressed,
+		SampledOut:      matched - len(clients),
+		ByPhoneOperator: map[int]int{},
+		ByTimezone:      map[string]int{},
+		ByTag:           map[string]int{},
+		ByLocale:        map[string]int{},
+		Samples:         []*models.PreviewMessage{},
+		RateLimit:       m.rateLimit(),
+	}
+	for i, client := range clients {
+		preview.ByPhoneOperator[client.PhoneOperator]++
+		preview.ByTimezone[client.Timezone]++
+		for _, tag := range client.Tags {
+			preview.ByTag[tag]++
+		}
+		preview.ByLocale[text.locale(client)]++
+		if i < samples {
+			sample := &models.PreviewMessage{ClientID: client.ID, PhoneNumber: client.PhoneNumber}
+			var err error
+			sample.Text, sample.Locale, err = text.render(client)
+			if err != nil {
+				sample.Error = err.Error()
+			}
+			sample.Segments = models.AnalyzeText(sample.Text).Segments
+			preview.Samples = append(preview.Samples, sample)
+		}
+	}
+	if preview.RateLimit > 0 {
+		preview.EstimatedDuration = time.Duration(len(clients)) * time.Second / time.Duration(preview.RateLimit)
+	}
+	start := mailing.StartTime
+	if now := time.Now(); start.Before(now) {
+		start = now
+	}
+	preview.FitsWindow = start.Add(preview.EstimatedDuration).Before(mailing.EndTime)
+	return preview, nil
+}
+
+// rateLimit returns the maximum amount of messages sent per second, 0 means no limit.
+func (m *MailingService) rateLimit() int {
+	if limited, ok := m.MessageSender.(RateLimited); ok {
+		return limited.RateLimit()
+	}
+	return 0
+}
@@ -0,0 +1,72 @@
+package mailing
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/pkg/errors"
+	"go.uber.org/zap"
+
+	"mailing/internal/models"
+)
+
+var (
+	// ErrMailingNotFinished is returned when an operation requires mailing to be finished.
+	ErrMailingNotFinished = errors.New("mailing is not finished")
+	// ErrResendRunning is returned when follow-up run of mailing is started while another one is running.
+	ErrResendRunning = errors.New("follow-up run of mailing is already running")
+)
+
+// ResendFailed starts a follow-up run of finished mailing in background, targeting only clients whose message failed,
+// and returns it's number. The run isn't canceled along with ctx, it lasts until given end time.
+// Its stats are saved separately from the original run, once it is over.
+func (m *MailingService) ResendFailed(ctx context.Context, mailingID uuid.UUID, endTime time.Time) (int, error) {
+	l := zap.L()
+	mailing, err := m.Storage.GetMailingByID(ctx, mailingID)
+	if err != nil {
+		return 0, errors.Wrap(err, "get mailing by id")
+	}
+	if mailing.Status != models.MailingStatusDone && mailing.Status != models.MailingStatusCanceled &&
+		mailing.Status != models.MailingStatusBudgetExceeded {
+		return 0, ErrMailingNotFinished
+	}
+	// Run is numbered after saved stats, so runs of the same mailing can't overlap.
+	m.mu.Lock()
+	if m.resending[mailingID] {
+		m.mu.Unlock()
+		return 0, ErrResendRunning
+	}
+	m.resending[mailingID] = true
+	m.mu.Unlock()
+	done := func() {
+		m.mu.Lock()
+		delete(m.resending, mailingID)
+		m.mu.Unlock()
+	}
+	clients, err := m.Storage.GetFailedClients(ctx, mailing)
+	if err != nil {
+		done()
+		return 0, errors.Wrap(err, "get failed clients")
+	}
+	lastRun, err := m.Storage.GetLastRun(ctx, mailingID)
+	if err != nil {
+		done()
+		return 0, errors.Wrap(err, "get last run")
+	}
+	run := lastRun + 1
+	go func() {
+		defer done()
+		ctx, cancel := context.WithDeadline(context.WithoutCancel(ctx), endTime)
+		defer cancel()
+		l.Info(fmt.Sprintf("Started follow-up run %d on: %v", run, mailing.ID))
+		_, err := m.run(ctx, mailing, clients, run, 0)
+		if err != nil {
+			l.Warn(fmt.Sprintf("Follow-up run %d on %v was interrupted\nError: %v", run, mailing.ID, err))
+			return
+		}
+		l.Info(fmt.Sprintf("Finished follow-up run %d on: %v", run, mailing.ID))
+	}()
+	return run, nil
+}
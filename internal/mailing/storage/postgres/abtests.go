@@ -0,0 +1,71 @@
+package postgres
+
+import (
+	"context"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+	"github.com/pkg/errors"
+
+	"mailing/internal/models"
+)
+
+// GetVariantStats returns stats of A/B test variants of mailing in test stage, ordered by variant.
+// Skipped messages and messages of winner's rollout are not counted.
+func (p *Postgres) GetVariantStats(ctx context.Context, mailingID uuid.UUID) ([]models.VariantStats, error) {
+	query := `
+	SELECT variant, COUNT(*) AS messages,
+		COUNT(*) FILTER (WHERE status = @success) AS success,
+		COUNT(*) FILTER (WHERE status = @failed) AS fails,
+		COUNT(*) FILTER (WHERE status = @success AND clicks > 0) AS clicked
+	FROM message
+	WHERE mailing_id = @mailingID AND run = 0 AND variant > 0 AND NOT rollout AND status <> @skipped
+	GROUP BY variant
+	ORDER BY variant
+	`
+	args := pgx.NamedArgs{
+		"mailingID": mailingID,
+		"success":   int(models.SendStatusSuccess),
+		"failed":    int(models.SendStatusFailed),
+		"skipped":   int(models.SendStatusSkipped),
+	}
+	rows, err := p.db.Query(ctx, query, args)
+	if err != nil {
+		return nil, errors.Wrap(err, "select from message")
+	}
+	variants, err := pgx.CollectRows(rows, pgx.RowToStructByName[models.VariantStats])
+	if err != nil {
+		return nil, errors.Wrap(err, "collect rows variant stats")
+	}
+	return variants, nil
+}
+
+// ScheduleABTestRollout saves the time of rollout of mailing's A/B test winner and marks mailing as testing,
+// so that it is returned by GetPendingMailings once the rollout is due.
+func (p *Postgres) ScheduleABTestRollout(ctx context.Context, mailingID uuid.UUID, rolloutAt time.Time) error {
+	query := `
+	UPDATE mailing
+	SET ab_test = jsonb_set(ab_test, '{rolloutAt}', to_jsonb($2::timestamptz)), status = $3
+	WHERE id = $1 AND ab_test IS NOT NULL
+	`
+	_, err := p.db.Exec(ctx, query, mailingID, rolloutAt, models.MailingStatusTesting)
+	if err != nil {
+		return errors.Wrap(err, "update mailing")
+	}
+	return nil
+}
+
+// SaveABTestWinner saves the winning variant of mailing's A/B test.
+func (p *Postgres) SaveABTestWinner(ctx context.Context, mailingID uuid.UUID, winner int) error {
+	query := `
+	UPDATE mailing
+	SET ab_test = jsonb_set(ab_test, '{winner}', to_jsonb($2::integer))
+	WHERE id = $1 AND ab_test IS NOT NULL
+	`
+	_, err := p.db.Exec(ctx, query, mailingID, winner)
+	if err != nil {
+		return errors.Wrap(err, "update mailing")
+	}
+	return nil
+}
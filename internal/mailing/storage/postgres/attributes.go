@@ -0,0 +1,83 @@
+package postgres
+
+import (
+	"context"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/pkg/errors"
+
+	"mailing/internal/models"
+)
+
+// GetAttributeDefinitions returns definitions of all custom client's attributes.
+func (p *Postgres) GetAttributeDefinitions(ctx context.Context) ([]*models.AttributeDefinition, error) {
+	query := `
+	SELECT name, type, required, allowed_values
+	FROM attribute_definition
+	ORDER BY name
+	`
+	rows, err := p.db.Query(ctx, query)
+	if err != nil {
+		return nil, errors.Wrap(err, "select from attribute_definition")
+	}
+	definitions, err := pgx.CollectRows(rows, pgx.RowToAddrOfStructByName[models.AttributeDefinition])
+	if err != nil {
+		return nil, errors.Wrap(err, "collect rows")
+	}
+	return definitions, nil
+}
+
+// SaveAttributeDefinition saves definition of custom attribute, definition with the same name is replaced.
+// Type of existing attribute can't be changed, since clients may already have values of it.
+func (p *Postgres) SaveAttributeDefinition(ctx context.Context, definition *models.AttributeDefinition) error {
+	query := `
+	INSERT INTO attribute_definition(name, type, required, allowed_values)
+	VALUES ($1, $2, $3, $4)
+	ON CONFLICT (name) DO UPDATE
+	SET required = EXCLUDED.required, allowed_values = EXCLUDED.allowed_values
+	WHERE attribute_definition.type = EXCLUDED.type
+	`
+	allowedValues := definition.AllowedValues
+	if allowedValues == nil {
+		allowedValues = []string{}
+	}
+	tag, err := p.db.Exec(ctx, query, definition.Name, definition.Type, definition.Required, allowedValues)
+	if err != nil {
+		return errors.Wrap(err, "insert into attribute_definition")
+	}
+	if tag.RowsAffected() == 0 {
+		return errors.Wrap(models.ErrAttributeTypeConflict, definition.Name)
+	}
+	return nil
+}
+
+// DeleteAttributeDefinition deletes definition of custom attribute and removes it from all clients.
+func (p *Postgres) DeleteAttributeDefinition(ctx context.Context, name string) error {
+	tx, err := p.db.Begin(ctx)
+	if err != nil {
+		return errors.Wrap(err, "begin transaction")
+	}
+	defer tx.Rollback(ctx)
+	query := `
+	DELETE FROM attribute_definition
+	WHERE name = $1
+	`
+	_, err = tx.Exec(ctx, query, name)
+	if err != nil {
+		return errors.Wrap(err, "delete from attribute_definition")
+	}
+	query = `
+	UPDATE client
+	SET attributes = attributes - $1::text
+	WHERE attributes ? $1::text
+	`
+	_, err = tx.Exec(ctx, query, name)
+	if err != nil {
+		return errors.Wrap(err, "update client")
+	}
+	err = tx.Commit(ctx)
+	if err != nil {
+		return errors.Wrap(err, "commit transaction")
+	}
+	return nil
+}
@@ -0,0 +1,100 @@
+package postgres
+
+import (
+	"context"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
+	"github.com/pkg/errors"
+
+	"mailing/internal/models"
+)
+
+// consented is SQL condition matching clients of client table aliased as "c", that granted consent to SMS.
+const consented = `(
+	SELECT co.status
+	FROM consent co
+	WHERE co.client_id = c.id AND co.channel = '` + models.ConsentChannelSMS + `'
+	ORDER BY co.created_at DESC, co.id DESC
+	LIMIT 1
+) = '` + string(models.ConsentStatusGranted) + `'`
+
+const selectConsent = `
+	SELECT id, client_id, channel, status, source, proof, COALESCE(token, '') AS token, created_at
+	FROM consent
+	`
+
+// GetConsents returns consent records of client, the newest first.
+func (p *Postgres) GetConsents(ctx context.Context, clientID int64) ([]*models.Consent, error) {
+	query := selectConsent + `WHERE client_id = $1
+	ORDER BY created_at DESC, id DESC
+	`
+	rows, err := p.db.Query(ctx, query, clientID)
+	if err != nil {
+		return nil, errors.Wrap(err, "select from consent")
+	}
+	consents, err := pgx.CollectRows(rows, pgx.RowToAddrOfStructByName[models.Consent])
+	if err != nil {
+		return nil, errors.Wrap(err, "collect rows")
+	}
+	return consents, nil
+}
+
+// GetLatestConsent returns the current consent record of client in channel, nil if there is none.
+func (p *Postgres) GetLatestConsent(ctx context.Context, clientID int64, channel string) (*models.Consent, error) {
+	query := selectConsent + `WHERE client_id = $1 AND channel = $2
+	ORDER BY created_at DESC, id DESC
+	LIMIT 1
+	`
+	rows, err := p.db.Query(ctx, query, clientID, channel)
+	if err != nil {
+		return nil, errors.Wrap(err, "select from consent")
+	}
+	consent, err := pgx.CollectOneRow(rows, pgx.RowToAddrOfStructByName[models.Consent])
+	if errors.Is(err, pgx.ErrNoRows) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, errors.Wrap(err, "collect row")
+	}
+	return consent, nil
+}
+
+// GetConsentByToken returns pending consent record by token of confirmation link.
+func (p *Postgres) GetConsentByToken(ctx context.Context, token string) (*models.Consent, error) {
+	query := selectConsent + `WHERE token = $1
+	`
+	rows, err := p.db.Query(ctx, query, token)
+	if err != nil {
+		return nil, errors.Wrap(err, "select from consent")
+	}
+	consent, err := pgx.CollectOneRow(rows, pgx.RowToAddrOfStructByName[models.Consent])
+	if errors.Is(err, pgx.ErrNoRows) {
+		return nil, models.ErrConsentNotFound
+	}
+	if err != nil {
+		return nil, errors.Wrap(err, "collect row")
+	}
+	return consent, nil
+}
+
+// SaveConsent saves consent record in Storage.
+func (p *Postgres) SaveConsent(ctx context.Context, consent *models.Consent) error {
+	query := `
+	INSERT INTO consent(client_id, channel, status, source, proof, token, created_at)
+	VALUES ($1, $2, $3, $4, $5, NULLIF($6, ''), $7)
+	RETURNING id
+	`
+	consent.CreatedAt = time.Now().UTC()
+	err := p.db.QueryRow(ctx, query, consent.ClientID, consent.Channel, consent.Status, consent.Source, consent.Proof,
+		consent.Token, consent.CreatedAt).Scan(&consent.ID)
+	var pgErr *pgconn.PgError
+	if errors.As(err, &pgErr) && pgErr.Code == _foreignKeyViolation {
+		return models.ErrClientNotFound
+	}
+	if err != nil {
+		return errors.Wrap(err, "insert into consent")
+	}
+	return nil
+}
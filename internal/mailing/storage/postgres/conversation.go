@@ -0,0 +1,46 @@
+package postgres
+
+import (
+	"context"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/pkg/errors"
+
+	"mailing/internal/models"
+)
+
+// GetConversation returns messages sent to and received from client in chronological order.
+// Limit and offset select the latest messages, offset counts from the newest one.
+func (p *Postgres) GetConversation(ctx context.Context, clientID int64, limit, offset int) ([]*models.ConversationEntry, error) {
+	query := `
+	SELECT *
+	FROM (
+		SELECT 'outbound' AS direction, id, text, time_stamp AS time, status,
+			NULLIF(mailing_id, '00000000-0000-0000-0000-000000000000') AS mailing_id, NULL::integer AS reply_to
+		FROM message
+		WHERE client_id = @clientID
+		UNION ALL
+		SELECT 'inbound', id, text, received_at, NULL, NULL, reply_to
+		FROM inbound_message
+		WHERE client_id = @clientID
+		ORDER BY time DESC, direction, id DESC
+		LIMIT @limit
+		OFFSET @offset
+	) e
+	ORDER BY time, direction DESC, id
+	`
+	args := pgx.NamedArgs{
+		"clientID": clientID,
+		"limit":    limit,
+		"offset":   offset,
+	}
+	rows, err := p.db.Query(ctx, query, args)
+	if err != nil {
+		return nil, errors.Wrap(err, "select from message")
+	}
+	entries, err := pgx.CollectRows(rows, pgx.RowToAddrOfStructByName[models.ConversationEntry])
+	if err != nil {
+		return nil, errors.Wrap(err, "collect rows")
+	}
+	return entries, nil
+}
@@ -0,0 +1,211 @@
+package postgres
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+
+	"mailing/internal/models"
+)
+
+// filterColumns maps filter fields to columns of client table.
+// Only these columns may get into compiled SQL, values are always passed as parameters.
+var filterColumns = map[string]string{
+	models.FilterFieldPhoneNumber:   "c.phone_number",
+	models.FilterFieldPhoneOperator: "c.phone_operator",
+	models.FilterFieldTag:           "t.name",
+	models.FilterFieldTimezone:      "c.timezone",
+}
+
+// filterMultiValued maps multi-valued filter fields to FROM and WHERE clauses of subquery selecting client's values,
+// comparison on such field matches if any of the values matches.
+var filterMultiValued = map[string]string{
+	models.FilterFieldTag: "FROM client_tag ct JOIN tag t ON t.id = ct.tag_id WHERE ct.client_id = c.id",
+}
+
+// attributeCasts maps attribute types to SQL types their json text is cast to.
+var attributeCasts = map[models.AttributeType]string{
+	models.AttributeTypeString:  "text",
+	models.AttributeTypeNumber:  "numeric",
+	models.AttributeTypeDate:    "date",
+	models.AttributeTypeBoolean: "boolean",
+}
+
+// filterComparisons maps comparison operators to SQL.
+var filterComparisons = map[models.FilterOp]string{
+	models.FilterOpLt:  "<",
+	models.FilterOpLte: "<=",
+	models.FilterOpGt:  ">",
+	models.FilterOpGte: ">=",
+}
+
+// filterCompiler compiles filter expression to SQL condition on client table aliased as "c".
+type filterCompiler struct {
+	args []any
+	// attributes are definitions of custom attributes by name.
+	attributes map[string]*models.AttributeDefinition
+}
+
+// newFilterCompiler returns filter compiler aware of given custom attributes.
+func newFilterCompiler(attributes []*models.AttributeDefinition) *filterCompiler {
+	fc := &filterCompiler{attributes: map[string]*models.AttributeDefinition{}}
+	for _, a := range attributes {
+		fc.attributes[a.Name] = a
+	}
+	return fc
+}
+
+// param adds value to query parameters and returns it's placeholder.
+func (fc *filterCompiler) param(v any) string {
+	fc.args = append(fc.args, v)
+	return fmt.Sprintf("$%d", len(fc.args))
+}
+
+// compile returns SQL condition for given filter, nil filter matches any client.
+// Filter must be validated before compiling.
+func (fc *filterCompiler) compile(f *models.Filter) (string, error) {
+	if f == nil {
+		return "TRUE", nil
+	}
+	switch f.Op {
+	case models.FilterOpAnd, models.FilterOpOr:
+		if len(f.Args) == 0 {
+			if f.Op == models.FilterOpAnd {
+				return "TRUE", nil
+			}
+			return "FALSE", nil
+		}
+		conditions := []string{}
+		for _, arg := range f.Args {
+			condition, err := fc.compile(arg)
+			if err != nil {
+				return "", err
+			}
+			conditions = append(conditions, condition)
+		}
+		return "(" + strings.Join(conditions, fmt.Sprintf(" %s ", strings.ToUpper(string(f.Op)))) + ")", nil
+	case models.FilterOpNot:
+		condition, err := fc.compile(f.Args[0])
+		if err != nil {
+			return "", err
+		}
+		return "(NOT " + condition + ")", nil
+	}
+
+	if _, ok := f.Attribute(); ok {
+		return fc.compileAttribute(f)
+	}
+	column, ok := filterColumns[f.Field]
+	if !ok {
+		return "", errors.Errorf("unknown filter field %q", f.Field)
+	}
+	if f.Op == models.FilterOpAll {
+		return fc.compileAll(f, column)
+	}
+	condition, err := fc.compareColumn(f, column)
+	if err != nil {
+		return "", err
+	}
+	if from, ok := filterMultiValued[f.Field]; ok {
+		return fmt.Sprintf("EXISTS (SELECT 1 %s AND %s)", from, condition), nil
+	}
+	return condition, nil
+}
+
+// compileAll returns SQL condition matching if multi-valued field contains all of filter values.
+func (fc *filterCompiler) compileAll(f *models.Filter, column string) (string, error) {
+	from, ok := filterMultiValued[f.Field]
+	if !ok {
+		return "", errors.Errorf("all is not applicable to %s", f.Field)
+	}
+	values := []any{}
+	seen := map[any]bool{}
+	for _, v := range f.Values {
+		if !seen[v] {
+			seen[v] = true
+			values = append(values, v)
+		}
+	}
+	if len(values) == 0 {
+		return "TRUE", nil
+	}
+	placeholders := []string{}
+	for _, v := range values {
+		placeholders = append(placeholders, fc.param(v))
+	}
+	return fmt.Sprintf("(SELECT COUNT(DISTINCT %s) %s AND %s IN (%s)) = %d",
+		column, from, column, strings.Join(placeholders, ", "), len(values)), nil
+}
+
+// compileAttribute returns SQL condition on custom attribute, operands are converted to type of attribute.
+// Clients without attribute don't match any comparison.
+func (fc *filterCompiler) compileAttribute(f *models.Filter) (string, error) {
+	name, _ := f.Attribute()
+	definition, ok := fc.attributes[name]
+	if !ok {
+		return "", errors.Errorf("unknown attribute %q", name)
+	}
+	typed := *f
+	var err error
+	convert := func(v any) any {
+		if v == nil || err != nil {
+			return v
+		}
+		if f.Op == models.FilterOpPrefix {
+			// Prefix is matched against text representation of attribute.
+			if s, ok := v.(string); ok {
+				return s
+			}
+		}
+		v, err = definition.ParseValue(v)
+		if err == nil && definition.Type == models.AttributeTypeDate {
+			v, err = time.Parse(models.AttributeDateLayout, v.(string))
+		}
+		return v
+	}
+	typed.Value = convert(f.Value)
+	typed.From = convert(f.From)
+	typed.To = convert(f.To)
+	typed.Values = make([]any, len(f.Values))
+	for i, v := range f.Values {
+		typed.Values[i] = convert(v)
+	}
+	if err != nil {
+		return "", errors.Wrapf(err, "filter on %s", f.Field)
+	}
+	column := fmt.Sprintf("(c.attributes->>%s)::%s", fc.param(name), attributeCasts[definition.Type])
+	return fc.compareColumn(&typed, column)
+}
+
+// compareColumn returns SQL condition comparing column according to filter.
+func (fc *filterCompiler) compareColumn(f *models.Filter, column string) (string, error) {
+	switch f.Op {
+	case models.FilterOpEq:
+		return fmt.Sprintf("%s = %s", column, fc.param(f.Value)), nil
+	case models.FilterOpIn:
+		if len(f.Values) == 0 {
+			return "FALSE", nil
+		}
+		placeholders := []string{}
+		for _, v := range f.Values {
+			placeholders = append(placeholders, fc.param(v))
+		}
+		return fmt.Sprintf("%s IN (%s)", column, strings.Join(placeholders, ", ")), nil
+	case models.FilterOpPrefix:
+		return fmt.Sprintf("starts_with(%s::text, %s)", column, fc.param(fmt.Sprint(f.Value))), nil
+	case models.FilterOpLt, models.FilterOpLte, models.FilterOpGt, models.FilterOpGte:
+		return fmt.Sprintf("%s %s %s", column, filterComparisons[f.Op], fc.param(f.Value)), nil
+	case models.FilterOpRange:
+		conditions := []string{}
+		if f.From != nil {
+			conditions = append(conditions, fmt.Sprintf("%s >= %s", column, fc.param(f.From)))
+		}
+		if f.To != nil {
+			conditions = append(conditions, fmt.Sprintf("%s <= %s", column, fc.param(f.To)))
+		}
+		return "(" + strings.Join(conditions, " AND ") + ")", nil
+	}
+	return "", errors.Errorf("unknown filter operator %q", f.Op)
+}
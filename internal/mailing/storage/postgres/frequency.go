@@ -0,0 +1,48 @@
+package postgres
+
+import (
+	"context"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/pkg/errors"
+
+	"mailing/internal/models"
+)
+
+// GetRecentMailingMessages returns times of mailing messages sent or being sent to given clients since given time,
+// the newest first, by client's id. Standalone messages aren't returned.
+func (p *Postgres) GetRecentMailingMessages(ctx context.Context, clientIDs []int64, since time.Time) (map[int64][]time.Time, error) {
+	query := `
+	SELECT client_id, time_stamp
+	FROM message
+	WHERE client_id = ANY(@clientIDs) AND time_stamp >= @since
+		AND mailing_id <> '00000000-0000-0000-0000-000000000000' AND status IN (@pending, @success)
+	ORDER BY time_stamp DESC
+	`
+	args := pgx.NamedArgs{
+		"clientIDs": clientIDs,
+		"since":     since,
+		"pending":   int(models.SendStatusPending),
+		"success":   int(models.SendStatusSuccess),
+	}
+	rows, err := p.db.Query(ctx, query, args)
+	if err != nil {
+		return nil, errors.Wrap(err, "select from message")
+	}
+	defer rows.Close()
+	times := map[int64][]time.Time{}
+	for rows.Next() {
+		var clientID int64
+		var timeStamp time.Time
+		err = rows.Scan(&clientID, &timeStamp)
+		if err != nil {
+			return nil, errors.Wrap(err, "scan row")
+		}
+		times[clientID] = append(times[clientID], timeStamp)
+	}
+	if rows.Err() != nil {
+		return nil, errors.Wrap(rows.Err(), "iterate rows")
+	}
+	return times, nil
+}
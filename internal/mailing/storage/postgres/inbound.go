@@ -0,0 +1,46 @@
+package postgres
+
+import (
+	"context"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/pkg/errors"
+
+	"mailing/internal/models"
+)
+
+// SaveInboundMessage saves message received from client's phone in Storage.
+func (p *Postgres) SaveInboundMessage(ctx context.Context, msg *models.InboundMessage) error {
+	query := `
+	INSERT INTO inbound_message(phone_number, client_id, reply_to, text, received_at, stop)
+	VALUES ($1, $2, $3, $4, $5, $6)
+	RETURNING id
+	`
+	err := p.db.QueryRow(ctx, query, msg.PhoneNumber, msg.ClientID, msg.ReplyTo, msg.Text, msg.ReceivedAt,
+		msg.Stop).Scan(&msg.ID)
+	if err != nil {
+		return errors.Wrap(err, "insert into inbound_message")
+	}
+	return nil
+}
+
+// GetLastMessageID returns id of the last message sent to client, nil if client has no messages.
+// Skipped messages never reached client, so they are ignored.
+func (p *Postgres) GetLastMessageID(ctx context.Context, clientID int64) (*int64, error) {
+	query := `
+	SELECT id
+	FROM message
+	WHERE client_id = $1 AND status <> $2
+	ORDER BY time_stamp DESC, id DESC
+	LIMIT 1
+	`
+	var id int64
+	err := p.db.QueryRow(ctx, query, clientID, int(models.SendStatusSkipped)).Scan(&id)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, errors.Wrap(err, "select from message")
+	}
+	return &id, nil
+}
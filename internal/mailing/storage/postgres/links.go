@@ -0,0 +1,48 @@
+package postgres
+
+import (
+	"context"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/pkg/errors"
+
+	"mailing/internal/models"
+)
+
+// ClickLink counts click on short link against it's message and returns URL to redirect to.
+func (p *Postgres) ClickLink(ctx context.Context, code string) (string, error) {
+	tx, err := p.db.Begin(ctx)
+	if err != nil {
+		return "", errors.Wrap(err, "begin transaction")
+	}
+	defer tx.Rollback(ctx)
+	query := `
+	UPDATE link
+	SET clicks = clicks + 1
+	WHERE code = $1
+	RETURNING message_id, url
+	`
+	var messageID int64
+	var url string
+	err = tx.QueryRow(ctx, query, code).Scan(&messageID, &url)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return "", models.ErrLinkNotFound
+	}
+	if err != nil {
+		return "", errors.Wrap(err, "update link")
+	}
+	query = `
+	UPDATE message
+	SET clicks = clicks + 1
+	WHERE id = $1
+	`
+	_, err = tx.Exec(ctx, query, messageID)
+	if err != nil {
+		return "", errors.Wrap(err, "update message")
+	}
+	err = tx.Commit(ctx)
+	if err != nil {
+		return "", errors.Wrap(err, "commit transaction")
+	}
+	return url, nil
+}
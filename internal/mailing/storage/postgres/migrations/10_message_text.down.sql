@@ -0,0 +1 @@
+ALTER TABLE message DROP COLUMN IF EXISTS text;
@@ -0,0 +1,6 @@
+ALTER TABLE mailing DROP COLUMN IF EXISTS template_version;
+ALTER TABLE mailing DROP COLUMN IF EXISTS template_id;
+
+DROP TABLE IF EXISTS template_version;
+
+DROP TABLE IF EXISTS template;
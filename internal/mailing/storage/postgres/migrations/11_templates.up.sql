@@ -0,0 +1,16 @@
+CREATE TABLE IF NOT EXISTS template (
+	id serial PRIMARY KEY,
+	name varchar(100) NOT NULL UNIQUE
+);
+
+CREATE TABLE IF NOT EXISTS template_version (
+	template_id integer REFERENCES template(id) ON DELETE CASCADE,
+	version integer NOT NULL,
+	text text NOT NULL,
+	created_at timestamp NOT NULL,
+	PRIMARY KEY (template_id, version)
+);
+
+-- Mailing keeps a copy of template's text, so template may be deleted.
+ALTER TABLE mailing ADD COLUMN IF NOT EXISTS template_id integer REFERENCES template(id) ON DELETE SET NULL;
+ALTER TABLE mailing ADD COLUMN IF NOT EXISTS template_version integer;
@@ -0,0 +1,5 @@
+ALTER TABLE message DROP COLUMN IF EXISTS locale;
+
+ALTER TABLE mailing DROP COLUMN IF EXISTS variants;
+
+ALTER TABLE client DROP COLUMN IF EXISTS locale;
@@ -0,0 +1,5 @@
+ALTER TABLE client ADD COLUMN IF NOT EXISTS locale varchar(35) NOT NULL DEFAULT '';
+
+ALTER TABLE mailing ADD COLUMN IF NOT EXISTS variants jsonb NOT NULL DEFAULT '{}';
+
+ALTER TABLE message ADD COLUMN IF NOT EXISTS locale varchar(35) NOT NULL DEFAULT '';
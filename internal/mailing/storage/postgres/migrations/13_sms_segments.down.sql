@@ -0,0 +1,3 @@
+ALTER TABLE message DROP COLUMN IF EXISTS segments;
+
+ALTER TABLE mailing DROP COLUMN IF EXISTS max_segments;
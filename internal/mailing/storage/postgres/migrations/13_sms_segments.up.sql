@@ -0,0 +1,3 @@
+ALTER TABLE mailing ADD COLUMN IF NOT EXISTS max_segments integer NOT NULL DEFAULT 0;
+
+ALTER TABLE message ADD COLUMN IF NOT EXISTS segments integer NOT NULL DEFAULT 0;
@@ -0,0 +1,3 @@
+ALTER TABLE message DROP COLUMN IF EXISTS clicks;
+
+DROP TABLE IF EXISTS link;
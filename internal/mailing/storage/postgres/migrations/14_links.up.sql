@@ -0,0 +1,8 @@
+CREATE TABLE IF NOT EXISTS link (
+	code varchar(16) PRIMARY KEY,
+	message_id integer REFERENCES message(id) ON DELETE CASCADE,
+	url text NOT NULL,
+	clicks integer NOT NULL DEFAULT 0
+);
+
+ALTER TABLE message ADD COLUMN IF NOT EXISTS clicks integer NOT NULL DEFAULT 0;
@@ -0,0 +1,3 @@
+ALTER TABLE mailing_stats DROP COLUMN IF EXISTS suppressed;
+
+DROP TABLE IF EXISTS suppression;
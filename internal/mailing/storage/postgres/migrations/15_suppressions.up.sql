@@ -0,0 +1,11 @@
+CREATE TABLE IF NOT EXISTS suppression (
+	id serial PRIMARY KEY,
+	client_id integer UNIQUE REFERENCES client(id) ON DELETE CASCADE,
+	phone_number bigint UNIQUE,
+	reason varchar(500) NOT NULL DEFAULT '',
+	source varchar(100) NOT NULL,
+	created_at timestamp NOT NULL,
+	CHECK (client_id IS NOT NULL OR phone_number IS NOT NULL)
+);
+
+ALTER TABLE mailing_stats ADD COLUMN IF NOT EXISTS suppressed integer NOT NULL DEFAULT 0;
@@ -0,0 +1,11 @@
+CREATE TABLE IF NOT EXISTS inbound_message (
+	id serial PRIMARY KEY,
+	phone_number bigint NOT NULL,
+	client_id integer REFERENCES client(id) ON DELETE SET NULL,
+	reply_to integer REFERENCES message(id) ON DELETE SET NULL,
+	text varchar(5000) NOT NULL,
+	received_at timestamp NOT NULL,
+	stop boolean NOT NULL DEFAULT false
+);
+
+CREATE INDEX IF NOT EXISTS inbound_message_client_id_idx ON inbound_message(client_id);
@@ -0,0 +1,3 @@
+ALTER TABLE mailing DROP COLUMN IF EXISTS require_consent;
+
+DROP TABLE IF EXISTS consent;
@@ -0,0 +1,14 @@
+CREATE TABLE IF NOT EXISTS consent (
+	id serial PRIMARY KEY,
+	client_id integer NOT NULL REFERENCES client(id) ON DELETE CASCADE,
+	channel varchar(20) NOT NULL,
+	status varchar(20) NOT NULL,
+	source varchar(100) NOT NULL,
+	proof varchar(1000) NOT NULL DEFAULT '',
+	token varchar(64) UNIQUE,
+	created_at timestamp NOT NULL
+);
+
+CREATE INDEX IF NOT EXISTS consent_client_id_idx ON consent(client_id, channel, created_at);
+
+ALTER TABLE mailing ADD COLUMN IF NOT EXISTS require_consent boolean NOT NULL DEFAULT false;
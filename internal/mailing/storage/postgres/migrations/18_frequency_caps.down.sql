@@ -0,0 +1,5 @@
+DROP INDEX IF EXISTS message_client_id_time_stamp_idx;
+
+ALTER TABLE mailing_stats DROP COLUMN IF EXISTS skipped;
+
+ALTER TABLE message DROP COLUMN IF EXISTS skip_reason;
@@ -0,0 +1,5 @@
+ALTER TABLE message ADD COLUMN IF NOT EXISTS skip_reason varchar(200) NOT NULL DEFAULT '';
+
+ALTER TABLE mailing_stats ADD COLUMN IF NOT EXISTS skipped integer NOT NULL DEFAULT 0;
+
+CREATE INDEX IF NOT EXISTS message_client_id_time_stamp_idx ON message(client_id, time_stamp);
@@ -0,0 +1,5 @@
+DROP TABLE IF EXISTS mailing_holdout;
+
+ALTER TABLE mailing_stats DROP COLUMN IF EXISTS held_out;
+
+ALTER TABLE mailing DROP COLUMN IF EXISTS holdout_percent;
@@ -0,0 +1,10 @@
+ALTER TABLE mailing ADD COLUMN IF NOT EXISTS holdout_percent integer NOT NULL DEFAULT 0;
+
+ALTER TABLE mailing_stats ADD COLUMN IF NOT EXISTS held_out integer NOT NULL DEFAULT 0;
+
+-- Clients are not referenced, so the holdout group outlives deleted clients like the audience snapshot.
+CREATE TABLE IF NOT EXISTS mailing_holdout (
+	mailing_id uuid REFERENCES mailing(id) ON DELETE CASCADE,
+	client_id integer NOT NULL,
+	PRIMARY KEY (mailing_id, client_id)
+);
@@ -0,0 +1,3 @@
+ALTER TABLE message DROP COLUMN IF EXISTS variant;
+
+ALTER TABLE mailing DROP COLUMN IF EXISTS ab_test;
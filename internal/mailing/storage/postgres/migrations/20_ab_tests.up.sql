@@ -0,0 +1,3 @@
+ALTER TABLE mailing ADD COLUMN IF NOT EXISTS ab_test jsonb;
+
+ALTER TABLE message ADD COLUMN IF NOT EXISTS variant integer NOT NULL DEFAULT 0;
@@ -0,0 +1,3 @@
+ALTER TABLE mailing DROP COLUMN IF EXISTS sample_seed;
+ALTER TABLE mailing DROP COLUMN IF EXISTS max_recipients;
+ALTER TABLE mailing DROP COLUMN IF EXISTS sample_percent;
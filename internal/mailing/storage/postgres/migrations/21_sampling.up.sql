@@ -0,0 +1,3 @@
+ALTER TABLE mailing ADD COLUMN IF NOT EXISTS sample_percent integer NOT NULL DEFAULT 0;
+ALTER TABLE mailing ADD COLUMN IF NOT EXISTS max_recipients integer NOT NULL DEFAULT 0;
+ALTER TABLE mailing ADD COLUMN IF NOT EXISTS sample_seed bigint NOT NULL DEFAULT 0;
@@ -0,0 +1,7 @@
+ALTER TABLE mailing DROP COLUMN IF EXISTS budget;
+
+ALTER TABLE mailing_stats DROP COLUMN IF EXISTS cost;
+
+ALTER TABLE message DROP COLUMN IF EXISTS cost;
+
+DROP TABLE IF EXISTS price;
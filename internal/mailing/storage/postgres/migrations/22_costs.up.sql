@@ -0,0 +1,13 @@
+CREATE TABLE IF NOT EXISTS price (
+	id serial PRIMARY KEY,
+	phone_operator integer NOT NULL DEFAULT 0,
+	provider varchar(100) NOT NULL DEFAULT '',
+	price bigint NOT NULL,
+	UNIQUE (phone_operator, provider)
+);
+
+ALTER TABLE message ADD COLUMN IF NOT EXISTS cost bigint NOT NULL DEFAULT 0;
+
+ALTER TABLE mailing_stats ADD COLUMN IF NOT EXISTS cost bigint NOT NULL DEFAULT 0;
+
+ALTER TABLE mailing ADD COLUMN IF NOT EXISTS budget bigint NOT NULL DEFAULT 0;
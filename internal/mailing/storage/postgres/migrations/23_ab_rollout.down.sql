@@ -0,0 +1,3 @@
+DROP INDEX IF EXISTS mailing_stats_mailing_id_run_idx;
+
+ALTER TABLE message DROP COLUMN IF EXISTS rollout;
@@ -0,0 +1,3 @@
+ALTER TABLE message ADD COLUMN IF NOT EXISTS rollout boolean NOT NULL DEFAULT false;
+
+CREATE UNIQUE INDEX IF NOT EXISTS mailing_stats_mailing_id_run_idx ON mailing_stats(mailing_id, run);
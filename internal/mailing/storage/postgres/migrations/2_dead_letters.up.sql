@@ -0,0 +1 @@
+ALTER TABLE message ADD COLUMN IF NOT EXISTS last_error text NOT NULL DEFAULT '';
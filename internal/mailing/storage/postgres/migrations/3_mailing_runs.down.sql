@@ -0,0 +1,3 @@
+DELETE FROM mailing_stats WHERE run > 0;
+ALTER TABLE mailing_stats DROP COLUMN IF EXISTS run;
+ALTER TABLE message DROP COLUMN IF EXISTS run;
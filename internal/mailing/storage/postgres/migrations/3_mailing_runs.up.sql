@@ -0,0 +1,2 @@
+ALTER TABLE message ADD COLUMN IF NOT EXISTS run integer NOT NULL DEFAULT 0;
+ALTER TABLE mailing_stats ADD COLUMN IF NOT EXISTS run integer NOT NULL DEFAULT 0;
@@ -0,0 +1,3 @@
+ALTER TABLE mailing_stats DROP COLUMN IF EXISTS expired;
+ALTER TABLE message DROP COLUMN IF EXISTS expires_at;
+ALTER TABLE mailing DROP COLUMN IF EXISTS ttl;
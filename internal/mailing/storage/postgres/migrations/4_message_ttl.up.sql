@@ -0,0 +1,3 @@
+ALTER TABLE mailing ADD COLUMN IF NOT EXISTS ttl interval NOT NULL DEFAULT '0';
+ALTER TABLE message ADD COLUMN IF NOT EXISTS expires_at TIMESTAMP;
+ALTER TABLE mailing_stats ADD COLUMN IF NOT EXISTS expired integer NOT NULL DEFAULT 0;
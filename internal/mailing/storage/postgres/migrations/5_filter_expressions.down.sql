@@ -0,0 +1,20 @@
+CREATE TABLE IF NOT EXISTS mailing_filter (
+	mailing_id uuid REFERENCES mailing(id) ON DELETE CASCADE,
+	phone_operator integer,
+	tag varchar(100),
+	timezone varchar(100)
+);
+
+-- Only equality conditions on the top level of filter can be kept.
+INSERT INTO mailing_filter
+SELECT m.id,
+	COALESCE((SELECT (a->>'value')::integer FROM jsonb_array_elements(m.filter->'args') a
+		WHERE a->>'op' = 'eq' AND a->>'field' = 'phoneOperator' LIMIT 1), 0),
+	COALESCE((SELECT a->>'value' FROM jsonb_array_elements(m.filter->'args') a
+		WHERE a->>'op' = 'eq' AND a->>'field' = 'tag' LIMIT 1), ''),
+	COALESCE((SELECT a->>'value' FROM jsonb_array_elements(m.filter->'args') a
+		WHERE a->>'op' = 'eq' AND a->>'field' = 'timezone' LIMIT 1), '')
+FROM mailing m
+WHERE m.id <> '00000000-0000-0000-0000-000000000000';
+
+ALTER TABLE mailing DROP COLUMN IF EXISTS filter;
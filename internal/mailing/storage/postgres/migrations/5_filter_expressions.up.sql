@@ -0,0 +1,21 @@
+ALTER TABLE mailing ADD COLUMN IF NOT EXISTS filter jsonb;
+
+-- Flat filters become AND of equality conditions, zero values meaning "any" are omitted.
+UPDATE mailing m
+SET filter = jsonb_build_object('op', 'and', 'args', (
+	SELECT COALESCE(jsonb_agg(c.cond), '[]'::jsonb)
+	FROM (
+		SELECT jsonb_build_object('op', 'eq', 'field', 'phoneOperator', 'value', f.phone_operator) AS cond
+		WHERE f.phone_operator <> 0
+		UNION ALL
+		SELECT jsonb_build_object('op', 'eq', 'field', 'tag', 'value', f.tag)
+		WHERE f.tag <> ''
+		UNION ALL
+		SELECT jsonb_build_object('op', 'eq', 'field', 'timezone', 'value', f.timezone)
+		WHERE f.timezone <> ''
+	) c
+))
+FROM mailing_filter f
+WHERE f.mailing_id = m.id;
+
+DROP TABLE IF EXISTS mailing_filter;
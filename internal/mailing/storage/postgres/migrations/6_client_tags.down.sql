@@ -0,0 +1,10 @@
+ALTER TABLE client ADD COLUMN IF NOT EXISTS tag varchar(100);
+
+-- Only one tag per client can be kept.
+UPDATE client c
+SET tag = (
+	SELECT MIN(t.name) FROM client_tag ct JOIN tag t ON t.id = ct.tag_id WHERE ct.client_id = c.id
+);
+
+DROP TABLE IF EXISTS client_tag;
+DROP TABLE IF EXISTS tag;
@@ -0,0 +1,19 @@
+CREATE TABLE IF NOT EXISTS tag (
+	id serial PRIMARY KEY,
+	name varchar(100) NOT NULL UNIQUE
+);
+
+CREATE TABLE IF NOT EXISTS client_tag (
+	client_id integer REFERENCES client(id) ON DELETE CASCADE,
+	tag_id integer REFERENCES tag(id) ON DELETE CASCADE,
+	PRIMARY KEY (client_id, tag_id)
+);
+
+INSERT INTO tag(name)
+SELECT DISTINCT tag FROM client WHERE tag IS NOT NULL AND tag <> ''
+ON CONFLICT DO NOTHING;
+
+INSERT INTO client_tag(client_id, tag_id)
+SELECT c.id, t.id FROM client c JOIN tag t ON t.name = c.tag;
+
+ALTER TABLE client DROP COLUMN IF EXISTS tag;
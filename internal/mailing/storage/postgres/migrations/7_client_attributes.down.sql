@@ -0,0 +1,3 @@
+ALTER TABLE client DROP COLUMN IF EXISTS attributes;
+
+DROP TABLE IF EXISTS attribute_definition;
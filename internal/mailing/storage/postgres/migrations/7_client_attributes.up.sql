@@ -0,0 +1,8 @@
+CREATE TABLE IF NOT EXISTS attribute_definition (
+	name varchar(100) PRIMARY KEY,
+	type varchar(20) NOT NULL,
+	required boolean NOT NULL DEFAULT false,
+	allowed_values varchar[] NOT NULL DEFAULT '{}'
+);
+
+ALTER TABLE client ADD COLUMN IF NOT EXISTS attributes jsonb NOT NULL DEFAULT '{}';
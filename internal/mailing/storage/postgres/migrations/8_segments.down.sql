@@ -0,0 +1,3 @@
+ALTER TABLE mailing DROP COLUMN IF EXISTS segment_id;
+
+DROP TABLE IF EXISTS segment;
@@ -0,0 +1,7 @@
+CREATE TABLE IF NOT EXISTS segment (
+	id serial PRIMARY KEY,
+	name varchar(100) NOT NULL UNIQUE,
+	filter jsonb
+);
+
+ALTER TABLE mailing ADD COLUMN IF NOT EXISTS segment_id integer REFERENCES segment(id) ON DELETE RESTRICT;
@@ -0,0 +1,3 @@
+DROP TABLE IF EXISTS mailing_audience;
+
+ALTER TABLE mailing DROP COLUMN IF EXISTS audience_mode;
@@ -0,0 +1,8 @@
+ALTER TABLE mailing ADD COLUMN IF NOT EXISTS audience_mode varchar(20) NOT NULL DEFAULT 'start';
+
+-- Clients are not referenced, so the snapshot outlives deleted clients.
+CREATE TABLE IF NOT EXISTS mailing_audience (
+	mailing_id uuid REFERENCES mailing(id) ON DELETE CASCADE,
+	client_id integer NOT NULL,
+	PRIMARY KEY (mailing_id, client_id)
+);
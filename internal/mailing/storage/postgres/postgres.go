@@ -61,13 +61,19 @@ func (p *Postgres) MigrateUp(ctx context.Context) error {
 	return nil
 }
 
+// selectClient is a query selecting clients with their tags, conditions may be appended to it.
+const selectClient = `
+	SELECT c.id, c.phone_number, c.phone_operator, c.timezone, c.attributes, c.locale,
+		ARRAY(
+			SELECT t.name FROM client_tag ct JOIN tag t ON t.id = ct.tag_id
+			WHERE ct.client_id = c.id ORDER BY t.name
+		) AS tags
+	FROM client c
+	`
+
 // GetClients returns all clients.
 func (p *Postgres) GetClients(ctx context.Context) ([]*models.Client, error) {
-	query := `
-	SELECT *
-	FROM client
-	`
-	rows, err := p.db.Query(ctx, query)
+	rows, err := p.db.Query(ctx, selectClient)
 	if err != nil {
 		return nil, errors.Wrap(err, "select from client")
 	}
@@ -80,16 +86,36 @@ func (p *Postgres) GetClients(ctx context.Context) ([]*models.Client, error) {
 
 // GetClientByID returns client by id.
 func (p *Postgres) GetClientByID(ctx context.Context, id int64) (*models.Client, error) {
-	query := `
-	SELECT *
-	FROM client
-	WHERE id = $1
+	query := selectClient + `WHERE c.id = $1
 	`
 	row, err := p.db.Query(ctx, query, id)
 	if err != nil {
 		return nil, errors.Wrap(err, "select from client")
 	}
 	client, err := pgx.CollectOneRow(row, pgx.RowToAddrOfStructByName[models.Client])
+	if errors.Is(err, pgx.ErrNoRows) {
+		return nil, models.ErrClientNotFound
+	}
+	if err != nil {
+		return nil, errors.Wrap(err, "collect row")
+	}
+	return client, nil
+}
+
+// GetClientByPhoneNumber returns client by phone number, the oldest one if several clients share it.
+func (p *Postgres) GetClientByPhoneNumber(ctx context.Context, phoneNumber int64) (*models.Client, error) {
+	query := selectClient + `WHERE c.phone_number = $1
+	ORDER BY c.id
+	LIMIT 1
+	`
+	row, err := p.db.Query(ctx, query, phoneNumber)
+	if err != nil {
+		return nil, errors.Wrap(err, "select from client")
+	}
+	client, err := pgx.CollectOneRow(row, pgx.RowToAddrOfStructByName[models.Client])
+	if errors.Is(err, pgx.ErrNoRows) {
+		return nil, models.ErrClientNotFound
+	}
 	if err != nil {
 		return nil, errors.Wrap(err, "collect row")
 	}
@@ -99,14 +125,32 @@ func (p *Postgres) GetClientByID(ctx context.Context, id int64) (*models.Client,
 // SaveClient saves client with all his atributes in Storage.
 func (p *Postgres) SaveClient(ctx context.Context, client *models.Client) error {
 	l := zap.L()
+	tx, err := p.db.Begin(ctx)
+	if err != nil {
+		return errors.Wrap(err, "begin transaction")
+	}
+	defer tx.Rollback(ctx)
 	query := `
-	INSERT INTO client(phone_number, phone_operator, tag, timezone)
-	VALUES ($1, $2, $3, $4)
+	INSERT INTO client(phone_number, phone_operator, timezone, attributes, locale)
+	VALUES ($1, $2, $3, $4, $5)
+	RETURNING id
 	`
-	_, err := p.db.Exec(ctx, query, client.PhoneNumber, client.PhoneOperator, client.Tag, client.Timezone)
+	if client.Attributes == nil {
+		client.Attributes = map[string]any{}
+	}
+	err = tx.QueryRow(ctx, query, client.PhoneNumber, client.PhoneOperator, client.Timezone, client.Attributes,
+		client.Locale).Scan(&client.ID)
 	if err != nil {
 		return errors.Wrap(err, "insert into client")
 	}
+	err = addTags(ctx, tx, []int64{client.ID}, client.Tags)
+	if err != nil {
+		return err
+	}
+	err = tx.Commit(ctx)
+	if err != nil {
+		return errors.Wrap(err, "commit transaction")
+	}
 	l.Info(fmt.Sprintf("Saved client: %d", client.ID))
 	return nil
 }
@@ -114,6 +158,11 @@ func (p *Postgres) SaveClient(ctx context.Context, client *models.Client) error
 // UpdateClient applies given update to client from storage by given id.
 func (p *Postgres) UpdateClient(ctx context.Context, id int64, update *models.ClientUpdate) error {
 	l := zap.L()
+	tx, err := p.db.Begin(ctx)
+	if err != nil {
+		return errors.Wrap(err, "begin transaction")
+	}
+	defer tx.Rollback(ctx)
 	query := `
 	UPDATE client
 	SET %s
@@ -126,27 +175,48 @@ func (p *Postgres) UpdateClient(ctx context.Context, id int64, update *models.Cl
 	if update.PhoneOperator != 0 {
 		updates = append(updates, "phone_operator = @phoneOperator")
 	}
-	if update.Tag != "" {
-		updates = append(updates, "tag = @tag")
-	}
 	if update.Timezone != "" {
 		updates = append(updates, "timezone = @timezone")
 	}
-
-	if len(updates) > 0 {
-		query = fmt.Sprintf(query, strings.Join(updates, ", "))
+	if len(update.Attributes) > 0 {
+		updates = append(updates, "attributes = jsonb_strip_nulls(attributes || @attributes)")
+	}
+	if update.Locale != "" {
+		updates = append(updates, "locale = @locale")
 	}
 	args := pgx.NamedArgs{
 		"phoneNumber":   update.PhoneNumber,
 		"phoneOperator": update.PhoneOperator,
-		"tag":           update.Tag,
 		"timezone":      update.Timezone,
+		"attributes":    update.Attributes,
+		"locale":        update.Locale,
 		"id":            id,
 	}
 
-	_, err := p.db.Exec(ctx, query, args)
+	if len(updates) > 0 {
+		query = fmt.Sprintf(query, strings.Join(updates, ", "))
+		_, err = tx.Exec(ctx, query, args)
+		if err != nil {
+			return errors.Wrap(err, "update client")
+		}
+	}
+	if update.Tags != nil {
+		query = `
+		DELETE FROM client_tag
+		WHERE client_id = $1
+		`
+		_, err = tx.Exec(ctx, query, id)
+		if err != nil {
+			return errors.Wrap(err, "delete from client_tag")
+		}
+		err = addTags(ctx, tx, []int64{id}, update.Tags)
+		if err != nil {
+			return err
+		}
+	}
+	err = tx.Commit(ctx)
 	if err != nil {
-		return errors.Wrap(err, "update client")
+		return errors.Wrap(err, "commit transaction")
 	}
 	l.Info(fmt.Sprintf("Updated client: %d", id))
 	return nil
@@ -165,68 +235,147 @@ func (p *Postgres) DeleteClient(ctx context.Context, id int64) error {
 	return nil
 }
 
+// selectMailing is a query selecting mailings, conditions may be appended to it.
+// The dependency for standalone messages is not a mailing, so it is never selected.
+const selectMailing = `
+	SELECT m.id, m.text, m.start_time, m.end_time, m.status, m.ttl, m.filter, m.segment_id, m.audience_mode,
+		m.template_id, COALESCE(m.template_version, 0), m.variants, m.max_segments,
+		m.require_consent, m.holdout_percent, m.ab_test, m.sample_percent, m.max_recipients, m.sample_seed,
+		m.budget
+	FROM mailing m
+	WHERE m.id <> '00000000-0000-0000-0000-000000000000'
+	`
+
+// scanMailing scans mailing selected by selectMailing query.
+func scanMailing(row pgx.Row) (*models.Mailing, error) {
+	var id uuid.UUID
+	var text string
+	var startTime, endTime time.Time
+	var status models.MailingStatus
+	var ttl time.Duration
+	var filter *models.Filter
+	var segmentID *int64
+	var audienceMode models.AudienceMode
+	var templateID *int64
+	var templateVersion int
+	var variants map[string]string
+	var maxSegments int
+	var requireConsent bool
+	var holdoutPercent int
+	var abTest *models.ABTest
+	var samplePercent, maxRecipients int
+	var sampleSeed, budget int64
+	err := row.Scan(&id, &text, &startTime, &endTime, &status, &ttl, &filter, &segmentID, &audienceMode,
+		&templateID, &templateVersion, &variants, &maxSegments, &requireConsent, &holdoutPercent, &abTest,
+		&samplePercent, &maxRecipients, &sampleSeed, &budget)
+	if err != nil {
+		return nil, err
+	}
+	return &models.Mailing{
+		ID:              id,
+		Text:            text,
+		StartTime:       startTime,
+		EndTime:         endTime,
+		Filter:          filter,
+		Status:          status,
+		TTL:             ttl,
+		SegmentID:       segmentID,
+		AudienceMode:    audienceMode,
+		TemplateID:      templateID,
+		TemplateVersion: templateVersion,
+		Variants:        variants,
+		MaxSegments:     maxSegments,
+		RequireConsent:  requireConsent,
+		HoldoutPercent:  holdoutPercent,
+		ABTest:          abTest,
+		SamplePercent:   samplePercent,
+		MaxRecipients:   maxRecipients,
+		SampleSeed:      sampleSeed,
+		Budget:          budget,
+	}, nil
+}
+
 // GetMailings return all mailings.
 func (p *Postgres) GetMailings(ctx context.Context) ([]*models.Mailing, error) {
-	query := `
-	SELECT m.id, m.text, m.start_time, m.end_time, m.status, f.phone_operator, f.tag, f.timezone
-	FROM mailing m , mailing_filter f
-	WHERE m.id = f.mailing_id
-	`
-	rows, err := p.db.Query(ctx, query)
+	rows, err := p.db.Query(ctx, selectMailing)
 	if err != nil {
 		return nil, errors.Wrap(err, "select from mailing")
 	}
 	defer rows.Close()
 	mailings := []*models.Mailing{}
 	for rows.Next() {
-		var id uuid.UUID
-		var text, tag, timezone string
-		var startTime, endTime time.Time
-		var phoneOperator int
-		var status models.MailingStatus
-		err := rows.Scan(&id, &text, &startTime, &endTime, &status, &phoneOperator, &tag, &timezone)
+		mailing, err := scanMailing(rows)
 		if err != nil {
 			return nil, errors.Wrap(err, "scan values")
 		}
-		mailings = append(mailings, &models.Mailing{
-			ID:        id,
-			Text:      text,
-			StartTime: startTime,
-			EndTime:   endTime,
-			Filter: &models.Filter{
-				PhoneOperator: phoneOperator,
-				Tag:           tag,
-				Timezone:      timezone,
-			},
-			Status: status,
-		})
+		mailings = append(mailings, mailing)
 	}
 	return mailings, nil
 }
 
+// GetMailingByID returns mailing by id.
+func (p *Postgres) GetMailingByID(ctx context.Context, id uuid.UUID) (*models.Mailing, error) {
+	query := selectMailing + `AND m.id = $1
+	`
+	mailing, err := scanMailing(p.db.QueryRow(ctx, query, id))
+	if err != nil {
+		return nil, errors.Wrap(err, "select from mailing")
+	}
+	return mailing, nil
+}
+
 // SaveMailing saves mailing with all it's atributes in Storage.
-func (p *Postgres) SaveMailing(ctx context.Context, mailing *models.Mailing) error { // TODO complex sql query
+// Audience of snapshot mailing is resolved and saved along with it.
+func (p *Postgres) SaveMailing(ctx context.Context, mailing *models.Mailing) error {
+	if mailing.AudienceMode == "" {
+		mailing.AudienceMode = models.AudienceModeStart
+	}
+	if mailing.Variants == nil {
+		mailing.Variants = map[string]string{}
+	}
+	tx, err := p.db.Begin(ctx)
+	if err != nil {
+		return errors.Wrap(err, "begin transaction")
+	}
+	defer tx.Rollback(ctx)
 	query := `
-	INSERT INTO mailing
-	VALUES ($1, $2, $3, $4, $5)
+	INSERT INTO mailing(id, text, start_time, end_time, status, ttl, filter, segment_id, audience_mode,
+		template_id, template_version, variants, max_segments, require_consent, holdout_percent, ab_test,
+		sample_percent, max_recipients, sample_seed, budget)
+	VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, NULLIF($11, 0), $12, $13, $14, $15, $16, $17, $18, $19, $20)
 	`
-	_, err := p.db.Exec(ctx, query, mailing.ID, mailing.Text, mailing.StartTime, mailing.EndTime, int(mailing.Status))
+	_, err = tx.Exec(ctx, query, mailing.ID, mailing.Text, mailing.StartTime, mailing.EndTime, int(mailing.Status),
+		mailing.TTL, mailing.Filter, mailing.SegmentID, mailing.AudienceMode, mailing.TemplateID, mailing.TemplateVersion,
+		mailing.Variants, mailing.MaxSegments, mailing.RequireConsent, mailing.HoldoutPercent, mailing.ABTest,
+		mailing.SamplePercent, mailing.MaxRecipients, mailing.SampleSeed, mailing.Budget)
 	if err != nil {
 		return errors.Wrap(err, "insert into mailing")
 	}
-	query = `
-	INSERT INTO mailing_filter
-	VALUES ($1, $2, $3, $4)
-	`
-	_, err = p.db.Exec(ctx, query, mailing.ID, mailing.Filter.PhoneOperator, mailing.Filter.Tag, mailing.Filter.Timezone)
+	if mailing.AudienceMode == models.AudienceModeSnapshot {
+		fc, condition, err := p.compileMailingFilter(ctx, mailing)
+		if err != nil {
+			return err
+		}
+		query = `
+		INSERT INTO mailing_audience(mailing_id, client_id)
+		SELECT ` + fc.param(mailing.ID) + `::uuid, c.id
+		FROM client c
+		WHERE ` + condition
+		_, err = tx.Exec(ctx, query, fc.args...)
+		if err != nil {
+			return errors.Wrap(err, "insert into mailing_audience")
+		}
+	}
+	err = tx.Commit(ctx)
 	if err != nil {
-		return errors.Wrap(err, "insert into mailing_filter")
+		return errors.Wrap(err, "commit transaction")
 	}
 	return nil
 }
 
 // UpdateMailing applies given update to mailing from storage by given id.
-func (p *Postgres) UpdateMailing(ctx context.Context, id uuid.UUID, update *models.MailingUpdate) error { // TODO complex sql query
+// Filter is replaced as a whole.
+func (p *Postgres) UpdateMailing(ctx context.Context, id uuid.UUID, update *models.MailingUpdate) error {
 	query := `
 	UPDATE mailing
 	SET %s
@@ -240,13 +389,65 @@ func (p *Postgres) UpdateMailing(ctx context.Context, id uuid.UUID, update *mode
 		updates = append(updates, "end_time = @endTime")
 	}
 	if update.Text != "" {
-		updates = append(updates, "text = @text")
+		updates = append(updates, "text = @text", "template_id = @templateID", "template_version = NULLIF(@templateVersion, 0)")
+	}
+	if update.TTL != 0 {
+		updates = append(updates, "ttl = @ttl")
+	}
+	if update.Filter != nil {
+		updates = append(updates, "filter = @filter")
+	}
+	if update.SegmentID != nil {
+		updates = append(updates, "segment_id = NULLIF(@segmentID, 0)")
+	}
+	if update.Variants != nil {
+		updates = append(updates, "variants = @variants")
+	}
+	if update.MaxSegments != nil {
+		updates = append(updates, "max_segments = @maxSegments")
+	}
+	if update.RequireConsent != nil {
+		updates = append(updates, "require_consent = @requireConsent")
+	}
+	if update.HoldoutPercent != nil {
+		updates = append(updates, "holdout_percent = @holdoutPercent")
+	}
+	if update.ABTest != nil && len(update.ABTest.Variants) == 0 {
+		updates = append(updates, "ab_test = NULL")
+	} else if update.ABTest != nil {
+		updates = append(updates, "ab_test = @abTest")
+	}
+	if update.SamplePercent != nil {
+		updates = append(updates, "sample_percent = @samplePercent")
+	}
+	if update.MaxRecipients != nil {
+		updates = append(updates, "max_recipients = @maxRecipients")
+	}
+	if update.SampleSeed != nil {
+		updates = append(updates, "sample_seed = @sampleSeed")
+	}
+	if update.Budget != nil {
+		updates = append(updates, "budget = @budget")
 	}
 	args := pgx.NamedArgs{
-		"startTime": update.StartTime,
-		"endTime":   update.EndTime,
-		"text":      update.Text,
-		"id":        id,
+		"startTime":       update.StartTime,
+		"endTime":         update.EndTime,
+		"text":            update.Text,
+		"ttl":             update.TTL,
+		"filter":          update.Filter,
+		"segmentID":       update.SegmentID,
+		"templateID":      update.TemplateID,
+		"templateVersion": update.TemplateVersion,
+		"variants":        update.Variants,
+		"maxSegments":     update.MaxSegments,
+		"requireConsent":  update.RequireConsent,
+		"holdoutPercent":  update.HoldoutPercent,
+		"abTest":          update.ABTest,
+		"samplePercent":   update.SamplePercent,
+		"maxRecipients":   update.MaxRecipients,
+		"sampleSeed":      update.SampleSeed,
+		"budget":          update.Budget,
+		"id":              id,
 	}
 
 	if len(updates) > 0 {
@@ -256,36 +457,6 @@ func (p *Postgres) UpdateMailing(ctx context.Context, id uuid.UUID, update *mode
 			return errors.Wrap(err, "update mailing")
 		}
 	}
-	query = `
-	UPDATE mailing_filter
-	SET %s
-	WHERE mailing_id = @id
-	`
-	updatesFilter := []string{}
-	if update.Filter != nil {
-		if update.Filter.PhoneOperator != 0 {
-			updatesFilter = append(updatesFilter, "phone_operator = @phoneOperator")
-		}
-		if update.Filter.Tag != "" {
-			updatesFilter = append(updatesFilter, "tag = @tag")
-		}
-		if update.Filter.Timezone != "" {
-			updatesFilter = append(updatesFilter, "timezone = @timezone")
-		}
-	}
-	args = pgx.NamedArgs{
-		"phoneOperator": update.Filter.PhoneOperator,
-		"tag":           update.Filter.Tag,
-		"timezone":      update.Filter.Timezone,
-		"id":            id,
-	}
-	if len(updatesFilter) > 0 {
-		query = fmt.Sprintf(query, strings.Join(updatesFilter, ", "))
-		_, err := p.db.Exec(ctx, query, args)
-		if err != nil {
-			return errors.Wrap(err, "update mailing_filter")
-		}
-	}
 	return nil
 }
 
@@ -316,55 +487,56 @@ func (p *Postgres) MarkMailing(ctx context.Context, mailing *models.Mailing, sta
 	return nil
 }
 
-// GetPendingMailings returns all mailings that were not yet started.
+// GetPendingMailings returns all mailings that were not yet started and A/B tested mailings due for rollout.
 func (p *Postgres) GetPendingMailings(ctx context.Context) ([]*models.Mailing, error) {
-	query := `
-	SELECT m.id, m.text, m.start_time, m.end_time, f.phone_operator, f.tag, f.timezone
-	FROM mailing m , mailing_filter f
-	WHERE m.id = f.mailing_id AND m.status = $1
+	query := selectMailing + `AND (m.status = $1 OR m.status = $2 AND (m.ab_test->>'rolloutAt')::timestamptz <= now())
 	`
-	rows, err := p.db.Query(ctx, query, models.MailingStatusPending)
+	rows, err := p.db.Query(ctx, query, models.MailingStatusPending, models.MailingStatusTesting)
 	if err != nil {
 		return nil, errors.Wrap(err, "select from mailing")
 	}
 	defer rows.Close()
 	mailings := []*models.Mailing{}
 	for rows.Next() {
-		var id uuid.UUID
-		var text, tag, timezone string
-		var startTime, endTime time.Time
-		var phoneOperator int
-		err := rows.Scan(&id, &text, &startTime, &endTime, &phoneOperator, &tag, &timezone)
+		mailing, err := scanMailing(rows)
 		if err != nil {
 			return nil, errors.Wrap(err, "scan values")
 		}
-		mailings = append(mailings, &models.Mailing{
-			ID:        id,
-			Text:      text,
-			StartTime: startTime,
-			EndTime:   endTime,
-			Filter: &models.Filter{
-				PhoneOperator: phoneOperator,
-				Tag:           tag,
-				Timezone:      timezone,
-			},
-		})
+		mailings = append(mailings, mailing)
 	}
 	return mailings, nil
 }
 
-// SaveMessage saves message in Storage.
+// SaveMessage saves message in Storage along with it's links.
 func (p *Postgres) SaveMessage(ctx context.Context, msg *models.Message) (int64, error) {
 	query := `
-	INSERT INTO message(time_stamp, mailing_id, client_id, status)
-	VALUES ($1, $2, $3, $4)
-	RETURNING id
+	WITH m AS (
+		INSERT INTO message(time_stamp, mailing_id, client_id, status, run, expires_at, text, locale, segments,
+			skip_reason, variant, cost, rollout)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $12, $13, $14, $15)
+		RETURNING id
+	), l AS (
+		INSERT INTO link(code, message_id, url)
+		SELECT l.code, m.id, l.url
+		FROM m, unnest($10::text[], $11::text[]) AS l(code, url)
+	)
+	SELECT id FROM m
 	`
+	codes, urls := []string{}, []string{}
+	for _, link := range msg.Links {
+		codes = append(codes, link.Code)
+		urls = append(urls, link.URL)
+	}
 	var id int64
-	err := p.db.QueryRow(ctx, query, msg.TimeStamp, msg.MailingID, msg.ClientID, int(msg.Status)).Scan(&id)
+	err := p.db.QueryRow(ctx, query, msg.TimeStamp, msg.MailingID, msg.ClientID, int(msg.Status), msg.Run, msg.ExpiresAt,
+		msg.Text, msg.Locale, msg.Segments, codes, urls, msg.SkipReason, msg.Variant, msg.Cost,
+		msg.Rollout).Scan(&id)
 	if err != nil {
 		return 0, errors.Wrap(err, "insert into message")
 	}
+	for _, link := range msg.Links {
+		link.MessageID = id
+	}
 	return id, nil
 }
 
@@ -382,6 +554,82 @@ func (p *Postgres) MarkMessage(ctx context.Context, msg *models.Message, status
 	return nil
 }
 
+// MarkMessageFailed marks message status as failed and saves the error it failed with.
+func (p *Postgres) MarkMessageFailed(ctx context.Context, msg *models.Message, lastErr string) error {
+	query := `
+	UPDATE message
+	SET status = $1, last_error = $2
+	WHERE id = $3
+	`
+	_, err := p.db.Exec(ctx, query, int(models.SendStatusFailed), lastErr, msg.ID)
+	if err != nil {
+		return errors.Wrap(err, "update message")
+	}
+	return nil
+}
+
+// RequeueMessage marks failed message as pending to resend it with it's new time stamp, expiry, segments and cost.
+// It reports false if message is no longer failed, e.g. if it is being resent already.
+func (p *Postgres) RequeueMessage(ctx context.Context, msg *models.Message) (bool, error) {
+	query := `
+	UPDATE message
+	SET status = $1, time_stamp = $2, expires_at = $3, segments = $4, cost = $5
+	WHERE id = $6 AND status = $7
+	`
+	tag, err := p.db.Exec(ctx, query, int(models.SendStatusPending), msg.TimeStamp, msg.ExpiresAt, msg.Segments, msg.Cost,
+		msg.ID, int(models.SendStatusFailed))
+	if err != nil {
+		return false, errors.Wrap(err, "update message")
+	}
+	return tag.RowsAffected() > 0, nil
+}
+
+// GetMessagesByIDs returns messages by given ids.
+func (p *Postgres) GetMessagesByIDs(ctx context.Context, ids []int64) ([]*models.Message, error) {
+	query := `
+	SELECT *
+	FROM message
+	WHERE id = ANY($1)
+	`
+	rows, err := p.db.Query(ctx, query, ids)
+	if err != nil {
+		return nil, errors.Wrap(err, "select from message")
+	}
+	messages, err := pgx.CollectRows(rows, pgx.RowToAddrOfStructByName[models.Message])
+	if err != nil {
+		return nil, errors.Wrap(err, "collect rows")
+	}
+	return messages, nil
+}
+
+// GetFailedMessages returns failed messages of given mailing or of all mailings if mailingID is nil.
+func (p *Postgres) GetFailedMessages(ctx context.Context, mailingID *uuid.UUID) ([]*models.Message, error) {
+	query := `
+	SELECT *
+	FROM message
+	WHERE status = @status
+	`
+	if mailingID != nil {
+		query += `AND mailing_id = @mailingID
+	`
+	}
+	query += `ORDER BY time_stamp DESC
+	`
+	args := pgx.NamedArgs{
+		"status":    int(models.SendStatusFailed),
+		"mailingID": mailingID,
+	}
+	rows, err := p.db.Query(ctx, query, args)
+	if err != nil {
+		return nil, errors.Wrap(err, "select from message")
+	}
+	messages, err := pgx.CollectRows(rows, pgx.RowToAddrOfStructByName[models.Message])
+	if err != nil {
+		return nil, errors.Wrap(err, "collect rows")
+	}
+	return messages, nil
+}
+
 // CommonStatistic returns common statistic for mailings.
 func (p *Postgres) CommonStatistic(ctx context.Context) ([]*models.MailingStats, error) {
 	query := `
@@ -406,40 +654,22 @@ func (p *Postgres) DetailedStatistic(ctx context.Context, mailingID uuid.UUID) (
 	SELECT *
 	FROM mailing_stats
 	WHERE mailing_id = $1
+	ORDER BY run
 	`
 	rows, err := p.db.Query(ctx, query, mailingID)
 	if err != nil {
 		return nil, errors.Wrap(err, "select from mailing_stats")
 	}
-	mailingStats, err := pgx.CollectOneRow(rows, pgx.RowToStructByName[models.MailingStats])
+	runStats, err := pgx.CollectRows(rows, pgx.RowToStructByName[models.MailingStats])
 	if err != nil {
-		return nil, errors.Wrap(err, "collect row mailing stats")
+		return nil, errors.Wrap(err, "collect rows mailing stats")
 	}
-	query = `
-	SELECT m.id, m.text, m.start_time, m.end_time, m.status, f.phone_operator, f.tag, f.timezone
-	FROM mailing m , mailing_filter f
-	WHERE m.id = f.mailing_id AND m.id = $1
-	`
-	var id uuid.UUID
-	var text, tag, timezone string
-	var startTime, endTime time.Time
-	var phoneOperator int
-	var status models.MailingStatus
-	err = p.db.QueryRow(ctx, query, mailingID).Scan(&id, &text, &startTime, &endTime, &status, &phoneOperator, &tag, &timezone)
-	if err != nil {
-		return nil, errors.Wrap(err, "select from mailing")
+	if len(runStats) == 0 {
+		return nil, errors.Wrap(pgx.ErrNoRows, "collect rows mailing stats")
 	}
-	attributes := models.Mailing{
-		ID:        id,
-		Text:      text,
-		StartTime: startTime,
-		EndTime:   endTime,
-		Filter: &models.Filter{
-			PhoneOperator: phoneOperator,
-			Tag:           tag,
-			Timezone:      timezone,
-		},
-		Status: status,
+	attributes, err := p.GetMailingByID(ctx, mailingID)
+	if err != nil {
+		return nil, err
 	}
 	query = `
 	SELECT *
@@ -455,42 +685,285 @@ func (p *Postgres) DetailedStatistic(ctx context.Context, mailingID uuid.UUID) (
 	if err != nil {
 		return nil, errors.Wrap(err, "collect rows messages")
 	}
+	query = `
+	SELECT locale, COUNT(*) AS messages,
+		COUNT(*) FILTER (WHERE status = @success) AS success,
+		COUNT(*) FILTER (WHERE status = @failed) AS fails
+	FROM message
+	WHERE mailing_id = @mailingID
+	GROUP BY locale
+	ORDER BY locale
+	`
+	args := pgx.NamedArgs{
+		"mailingID": mailingID,
+		"success":   int(models.SendStatusSuccess),
+		"failed":    int(models.SendStatusFailed),
+	}
+	rows, err = p.db.Query(ctx, query, args)
+	if err != nil {
+		return nil, errors.Wrap(err, "select from message")
+	}
+	locales, err := pgx.CollectRows(rows, pgx.RowToStructByName[models.LocaleStats])
+	if err != nil {
+		return nil, errors.Wrap(err, "collect rows locale stats")
+	}
+	query = `
+	SELECT COUNT(*) FILTER (WHERE status = @success) AS delivered,
+		COUNT(*) FILTER (WHERE clicks > 0) AS clicked,
+		COALESCE(SUM(clicks), 0) AS clicks
+	FROM message
+	WHERE mailing_id = @mailingID
+	`
+	rows, err = p.db.Query(ctx, query, args)
+	if err != nil {
+		return nil, errors.Wrap(err, "select from message")
+	}
+	clicks, err := pgx.CollectOneRow(rows, pgx.RowToStructByName[models.ClickStats])
+	if err != nil {
+		return nil, errors.Wrap(err, "collect row click stats")
+	}
+	variants, err := p.GetVariantStats(ctx, mailingID)
+	if err != nil {
+		return nil, err
+	}
 	return &models.DetailedMailingStats{
-		CommonStats: mailingStats,
-		Attributes:  attributes,
+		CommonStats: runStats[0],
+		FollowUps:   runStats[1:],
+		Attributes:  *attributes,
 		Messages:    messages,
+		Locales:     locales,
+		Clicks:      clicks,
+		Variants:    variants,
 	}, nil
 }
 
-// GetClientsForMailing gets clients which satisfy mailing's filter.
+// GetClientsForMailing gets clients which satisfy mailing's filter and belong to mailing's segment.
+// Clients of snapshot mailing are taken from it's saved audience instead. Suppressed clients are excluded.
 func (p *Postgres) GetClientsForMailing(ctx context.Context, mailing *models.Mailing) ([]*models.Client, error) {
+	fc, condition, err := p.mailingAudience(ctx, mailing)
+	if err != nil {
+		return nil, err
+	}
+	query := selectClient + `WHERE ` + condition + ` AND NOT ` + suppressed + `
+	ORDER BY c.id
+	`
+	rows, err := p.db.Query(ctx, query, fc.args...)
+	if err != nil {
+		return nil, errors.Wrap(err, "select from client")
+	}
+	clients, err := pgx.CollectRows(rows, pgx.RowToAddrOfStructByName[models.Client])
+	if err != nil {
+		return nil, errors.Wrap(err, "collect rows")
+	}
+	return clients, nil
+}
+
+// GetNewClientsForMailing gets clients which satisfy mailing's filter and belong to mailing's segment,
+// but have no message in the mailing yet and aren't held out. Suppressed clients are excluded.
+func (p *Postgres) GetNewClientsForMailing(ctx context.Context, mailing *models.Mailing) ([]*models.Client, error) {
+	fc, condition, err := p.mailingAudience(ctx, mailing)
+	if err != nil {
+		return nil, err
+	}
+	mailingID := fc.param(mailing.ID)
+	query := selectClient + `WHERE ` + condition + ` AND NOT ` + suppressed + `
+	AND NOT EXISTS (SELECT 1 FROM message m WHERE m.client_id = c.id AND m.mailing_id = ` + mailingID + `)
+	AND NOT EXISTS (SELECT 1 FROM mailing_holdout h WHERE h.client_id = c.id AND h.mailing_id = ` + mailingID + `)
+	ORDER BY c.id
+	`
+	rows, err := p.db.Query(ctx, query, fc.args...)
+	if err != nil {
+		return nil, errors.Wrap(err, "select from client")
+	}
+	clients, err := pgx.CollectRows(rows, pgx.RowToAddrOfStructByName[models.Client])
+	if err != nil {
+		return nil, errors.Wrap(err, "collect rows")
+	}
+	return clients, nil
+}
+
+// GetMailingAudience returns ids of clients in saved audience of snapshot mailing.
+// Ids of clients deleted after the snapshot are kept.
+func (p *Postgres) GetMailingAudience(ctx context.Context, mailingID uuid.UUID) ([]int64, error) {
 	query := `
-	SELECT *
-	FROM client
+	SELECT client_id
+	FROM mailing_audience
+	WHERE mailing_id = $1
+	ORDER BY client_id
+	`
+	rows, err := p.db.Query(ctx, query, mailingID)
+	if err != nil {
+		return nil, errors.Wrap(err, "select from mailing_audience")
+	}
+	ids, err := pgx.CollectRows(rows, pgx.RowTo[int64])
+	if err != nil {
+		return nil, errors.Wrap(err, "collect rows")
+	}
+	return ids, nil
+}
 
+// SaveMailingHoldout adds given clients to mailing's holdout group, clients already in it are ignored.
+func (p *Postgres) SaveMailingHoldout(ctx context.Context, mailingID uuid.UUID, clientIDs []int64) error {
+	query := `
+	INSERT INTO mailing_holdout(mailing_id, client_id)
+	SELECT $1, unnest($2::integer[])
+	ON CONFLICT DO NOTHING
 	`
-	filters := []string{}
-	if mailing.Filter != nil {
-		if mailing.Filter.PhoneOperator != 0 {
-			filters = append(filters, "phone_operator = @phoneOperator")
-		}
-		if mailing.Filter.Tag != "" {
-			filters = append(filters, "tag = @tag")
-		}
-		if mailing.Filter.Timezone != "" {
-			filters = append(filters, "timezone = @timezone")
+	_, err := p.db.Exec(ctx, query, mailingID, clientIDs)
+	if err != nil {
+		return errors.Wrap(err, "insert into mailing_holdout")
+	}
+	return nil
+}
+
+// GetMailingHoldout returns ids of clients in mailing's holdout group.
+// Ids of clients deleted after they were held out are kept.
+func (p *Postgres) GetMailingHoldout(ctx context.Context, mailingID uuid.UUID) ([]int64, error) {
+	query := `
+	SELECT client_id
+	FROM mailing_holdout
+	WHERE mailing_id = $1
+	ORDER BY client_id
+	`
+	rows, err := p.db.Query(ctx, query, mailingID)
+	if err != nil {
+		return nil, errors.Wrap(err, "select from mailing_holdout")
+	}
+	ids, err := pgx.CollectRows(rows, pgx.RowTo[int64])
+	if err != nil {
+		return nil, errors.Wrap(err, "collect rows")
+	}
+	return ids, nil
+}
+
+// mailingFilter returns filter matching clients, that satisfy mailing's filter and belong to mailing's segment.
+func (p *Postgres) mailingFilter(ctx context.Context, mailing *models.Mailing) (*models.Filter, error) {
+	if mailing.SegmentID == nil {
+		return mailing.Filter, nil
+	}
+	segment, err := p.GetSegmentByID(ctx, *mailing.SegmentID)
+	if err != nil {
+		return nil, errors.Wrap(err, "get segment by id")
+	}
+	filter := &models.Filter{Op: models.FilterOpAnd, Args: []*models.Filter{}}
+	for _, f := range []*models.Filter{mailing.Filter, segment.Filter} {
+		if f != nil {
+			filter.Args = append(filter.Args, f)
 		}
+	}
+	return filter, nil
+}
 
-		if len(filters) > 0 {
-			query += `WHERE `
-			query += strings.Join(filters, ` AND `)
+// mailingAudience returns SQL condition on client table aliased as "c" matching mailing's audience:
+// saved snapshot of snapshot mailing, mailing's filter and segment otherwise.
+// Mailing, that requires consent, matches only consenting clients.
+func (p *Postgres) mailingAudience(ctx context.Context, mailing *models.Mailing) (*filterCompiler, string, error) {
+	var fc *filterCompiler
+	var condition string
+	if mailing.AudienceMode == models.AudienceModeSnapshot {
+		fc = newFilterCompiler(nil)
+		condition = `c.id IN (SELECT client_id FROM mailing_audience WHERE mailing_id = ` + fc.param(mailing.ID) + `)`
+	} else {
+		var err error
+		fc, condition, err = p.compileMailingFilter(ctx, mailing)
+		if err != nil {
+			return nil, "", err
 		}
 	}
+	if mailing.RequireConsent {
+		condition = `(` + condition + `) AND ` + consented
+	}
+	return fc, condition, nil
+}
+
+// compileMailingFilter compiles mailing's filter and segment to SQL condition on client table aliased as "c".
+func (p *Postgres) compileMailingFilter(ctx context.Context, mailing *models.Mailing) (*filterCompiler, string, error) {
+	filter, err := p.mailingFilter(ctx, mailing)
+	if err != nil {
+		return nil, "", err
+	}
+	return p.compileFilter(ctx, filter)
+}
+
+// GetClientsByFilter gets clients which satisfy filter ordered by id, limit 0 means no limit.
+func (p *Postgres) GetClientsByFilter(ctx context.Context, filter *models.Filter, limit, offset int) ([]*models.Client, error) {
+	fc, condition, err := p.compileFilter(ctx, filter)
+	if err != nil {
+		return nil, err
+	}
+	query := selectClient + `WHERE ` + condition + `
+	ORDER BY c.id
+	OFFSET ` + fc.param(offset)
+	if limit > 0 {
+		query += ` LIMIT ` + fc.param(limit)
+	}
+	rows, err := p.db.Query(ctx, query, fc.args...)
+	if err != nil {
+		return nil, errors.Wrap(err, "select from client")
+	}
+	clients, err := pgx.CollectRows(rows, pgx.RowToAddrOfStructByName[models.Client])
+	if err != nil {
+		return nil, errors.Wrap(err, "collect rows")
+	}
+	return clients, nil
+}
+
+// CountClientsByFilter returns the amount of clients which satisfy filter.
+func (p *Postgres) CountClientsByFilter(ctx context.Context, filter *models.Filter) (int, error) {
+	fc, condition, err := p.compileFilter(ctx, filter)
+	if err != nil {
+		return 0, err
+	}
+	query := `
+	SELECT COUNT(*)
+	FROM client c
+	WHERE ` + condition
+	var count int
+	err = p.db.QueryRow(ctx, query, fc.args...).Scan(&count)
+	if err != nil {
+		return 0, errors.Wrap(err, "select from client")
+	}
+	return count, nil
+}
+
+// compileFilter validates filter and compiles it to SQL condition on client table aliased as "c".
+func (p *Postgres) compileFilter(ctx context.Context, filter *models.Filter) (*filterCompiler, string, error) {
+	err := filter.Validate()
+	if err != nil {
+		return nil, "", errors.Wrap(err, "validate filter")
+	}
+	attributes, err := p.GetAttributeDefinitions(ctx)
+	if err != nil {
+		return nil, "", err
+	}
+	fc := newFilterCompiler(attributes)
+	condition, err := fc.compile(filter)
+	if err != nil {
+		return nil, "", errors.Wrap(err, "compile filter")
+	}
+	return fc, condition, nil
+}
 
+// GetFailedClients gets clients whose message in given mailing failed and was never sent successfully.
+// Suppressed clients are excluded, as well as clients without consent if mailing requires it.
+func (p *Postgres) GetFailedClients(ctx context.Context, mailing *models.Mailing) ([]*models.Client, error) {
+	query := selectClient + `
+	WHERE EXISTS (
+		SELECT 1 FROM message m
+		WHERE m.client_id = c.id AND m.mailing_id = @mailingID AND m.status = @failed
+	) AND NOT EXISTS (
+		SELECT 1 FROM message m
+		WHERE m.client_id = c.id AND m.mailing_id = @mailingID AND m.status = @success
+	) AND NOT ` + suppressed + `
+	`
+	if mailing.RequireConsent {
+		query += `AND ` + consented + `
+	`
+	}
 	args := pgx.NamedArgs{
-		"phoneOperator": mailing.Filter.PhoneOperator,
-		"tag":           mailing.Filter.Tag,
-		"timezone":      mailing.Filter.Timezone,
+		"mailingID": mailing.ID,
+		"failed":    int(models.SendStatusFailed),
+		"success":   int(models.SendStatusSuccess),
 	}
 	rows, err := p.db.Query(ctx, query, args)
 	if err != nil {
@@ -503,14 +976,41 @@ func (p *Postgres) GetClientsForMailing(ctx context.Context, mailing *models.Mai
 	return clients, nil
 }
 
-// SaveStats saves stats of executed mailing.
+// GetLastRun returns the number of the last run of given mailing.
+func (p *Postgres) GetLastRun(ctx context.Context, mailingID uuid.UUID) (int, error) {
+	query := `
+	SELECT COALESCE(MAX(run), 0)
+	FROM mailing_stats
+	WHERE mailing_id = $1
+	`
+	var run int
+	err := p.db.QueryRow(ctx, query, mailingID).Scan(&run)
+	if err != nil {
+		return 0, errors.Wrap(err, "select from mailing_stats")
+	}
+	return run, nil
+}
+
+// SaveStats saves stats of executed mailing, stats of the run already saved are added up with them.
 func (p *Postgres) SaveStats(ctx context.Context, mailingStats *models.MailingStats) error {
 	query := `
-	INSERT INTO mailing_stats
-	VALUES ($1, $2, $3, $4, $5, $6)
+	INSERT INTO mailing_stats(mailing_id, matches, sent, fails, start_time, time_executing, run, expired, suppressed,
+		skipped, held_out, cost)
+	VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12)
+	ON CONFLICT (mailing_id, run) DO UPDATE
+	SET matches = mailing_stats.matches + EXCLUDED.matches,
+		sent = mailing_stats.sent + EXCLUDED.sent,
+		fails = mailing_stats.fails + EXCLUDED.fails,
+		time_executing = mailing_stats.time_executing + EXCLUDED.time_executing,
+		expired = mailing_stats.expired + EXCLUDED.expired,
+		suppressed = mailing_stats.suppressed + EXCLUDED.suppressed,
+		skipped = mailing_stats.skipped + EXCLUDED.skipped,
+		held_out = mailing_stats.held_out + EXCLUDED.held_out,
+		cost = mailing_stats.cost + EXCLUDED.cost
 	`
 	_, err := p.db.Exec(ctx, query, mailingStats.ID, mailingStats.Matches, mailingStats.Sent,
-		mailingStats.Fails, mailingStats.StartTime, mailingStats.TimeExecuting)
+		mailingStats.Fails, mailingStats.StartTime, mailingStats.TimeExecuting, mailingStats.Run, mailingStats.Expired,
+		mailingStats.Suppressed, mailingStats.Skipped, mailingStats.HeldOut, mailingStats.Cost)
 	if err != nil {
 		return errors.Wrap(err, "insert into mailing_stats")
 	}
@@ -0,0 +1,75 @@
+package postgres
+
+import (
+	"context"
+
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+	"github.com/pkg/errors"
+
+	"mailing/internal/models"
+)
+
+// GetPrices returns price table ordered by phone operator and provider.
+func (p *Postgres) GetPrices(ctx context.Context) ([]*models.Price, error) {
+	query := `
+	SELECT id, phone_operator, provider, price
+	FROM price
+	ORDER BY phone_operator, provider
+	`
+	rows, err := p.db.Query(ctx, query)
+	if err != nil {
+		return nil, errors.Wrap(err, "select from price")
+	}
+	prices, err := pgx.CollectRows(rows, pgx.RowToAddrOfStructByName[models.Price])
+	if err != nil {
+		return nil, errors.Wrap(err, "collect rows")
+	}
+	return prices, nil
+}
+
+// SavePrice adds price to price table or changes price of the same phone operator and provider.
+func (p *Postgres) SavePrice(ctx context.Context, price *models.Price) error {
+	query := `
+	INSERT INTO price(phone_operator, provider, price)
+	VALUES ($1, $2, $3)
+	ON CONFLICT (phone_operator, provider) DO UPDATE
+	SET price = EXCLUDED.price
+	RETURNING id
+	`
+	err := p.db.QueryRow(ctx, query, price.PhoneOperator, price.Provider, price.Price).Scan(&price.ID)
+	if err != nil {
+		return errors.Wrap(err, "insert into price")
+	}
+	return nil
+}
+
+// DeletePrice deletes price from price table by given id.
+func (p *Postgres) DeletePrice(ctx context.Context, id int64) error {
+	query := `
+	DELETE FROM price
+	WHERE id = $1
+	`
+	_, err := p.db.Exec(ctx, query, id)
+	if err != nil {
+		return errors.Wrap(err, "delete from price")
+	}
+	return nil
+}
+
+// GetMailingCost returns the total cost of mailing's messages in all runs.
+// Skipped messages and messages, that failed or expired, cost nothing.
+func (p *Postgres) GetMailingCost(ctx context.Context, mailingID uuid.UUID) (int64, error) {
+	query := `
+	SELECT COALESCE(SUM(cost), 0)
+	FROM message
+	WHERE mailing_id = $1 AND status NOT IN ($2, $3, $4)
+	`
+	var cost int64
+	err := p.db.QueryRow(ctx, query, mailingID, int(models.SendStatusFailed), int(models.SendStatusExpired),
+		int(models.SendStatusSkipped)).Scan(&cost)
+	if err != nil {
+		return 0, errors.Wrap(err, "select from message")
+	}
+	return cost, nil
+}
@@ -0,0 +1,117 @@
+package postgres
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
+	"github.com/pkg/errors"
+
+	"mailing/internal/models"
+)
+
+// _foreignKeyViolation is postgres error code of foreign key violation.
+const _foreignKeyViolation = "23503"
+
+// GetSegments returns all segments.
+func (p *Postgres) GetSegments(ctx context.Context) ([]*models.Segment, error) {
+	query := `
+	SELECT id, name, filter
+	FROM segment
+	ORDER BY id
+	`
+	rows, err := p.db.Query(ctx, query)
+	if err != nil {
+		return nil, errors.Wrap(err, "select from segment")
+	}
+	segments, err := pgx.CollectRows(rows, pgx.RowToAddrOfStructByName[models.Segment])
+	if err != nil {
+		return nil, errors.Wrap(err, "collect rows")
+	}
+	return segments, nil
+}
+
+// GetSegmentByID returns segment by id.
+func (p *Postgres) GetSegmentByID(ctx context.Context, id int64) (*models.Segment, error) {
+	query := `
+	SELECT id, name, filter
+	FROM segment
+	WHERE id = $1
+	`
+	row, err := p.db.Query(ctx, query, id)
+	if err != nil {
+		return nil, errors.Wrap(err, "select from segment")
+	}
+	segment, err := pgx.CollectOneRow(row, pgx.RowToAddrOfStructByName[models.Segment])
+	if errors.Is(err, pgx.ErrNoRows) {
+		return nil, models.ErrSegmentNotFound
+	}
+	if err != nil {
+		return nil, errors.Wrap(err, "collect row")
+	}
+	return segment, nil
+}
+
+// SaveSegment saves segment in Storage.
+func (p *Postgres) SaveSegment(ctx context.Context, segment *models.Segment) error {
+	query := `
+	INSERT INTO segment(name, filter)
+	VALUES ($1, $2)
+	RETURNING id
+	`
+	err := p.db.QueryRow(ctx, query, segment.Name, segment.Filter).Scan(&segment.ID)
+	if err != nil {
+		return errors.Wrap(err, "insert into segment")
+	}
+	return nil
+}
+
+// UpdateSegment applies given update to segment from storage by given id.
+func (p *Postgres) UpdateSegment(ctx context.Context, id int64, update *models.SegmentUpdate) error {
+	query := `
+	UPDATE segment
+	SET %s
+	WHERE id = @id
+	`
+	updates := []string{}
+	if update.Name != "" {
+		updates = append(updates, "name = @name")
+	}
+	if update.Filter != nil {
+		updates = append(updates, "filter = @filter")
+	}
+	args := pgx.NamedArgs{
+		"name":   update.Name,
+		"filter": update.Filter,
+		"id":     id,
+	}
+
+	if len(updates) > 0 {
+		query = fmt.Sprintf(query, strings.Join(updates, ", "))
+		_, err := p.db.Exec(ctx, query, args)
+		if err != nil {
+			return errors.Wrap(err, "update segment")
+		}
+	}
+	return nil
+}
+
+// DeleteSegment deletes segment from storage by given id.
+// Segment referenced by mailings can't be deleted.
+func (p *Postgres) DeleteSegment(ctx context.Context, id int64) error {
+	query := `
+	DELETE FROM segment
+	WHERE id = $1
+	`
+	_, err := p.db.Exec(ctx, query, id)
+	var pgErr *pgconn.PgError
+	if errors.As(err, &pgErr) && pgErr.Code == _foreignKeyViolation {
+		return models.ErrSegmentInUse
+	}
+	if err != nil {
+		return errors.Wrap(err, "delete from segment")
+	}
+	return nil
+}
@@ -0,0 +1,107 @@
+package postgres
+
+import (
+	"context"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
+	"github.com/pkg/errors"
+
+	"mailing/internal/models"
+)
+
+// _uniqueViolation is postgres error code of unique constraint violation.
+const _uniqueViolation = "23505"
+
+// suppressed is SQL condition matching suppressed clients of client table aliased as "c".
+const suppressed = `EXISTS (SELECT 1 FROM suppression s WHERE s.client_id = c.id OR s.phone_number = c.phone_number)`
+
+// GetSuppressions returns suppression list, the newest entries first.
+func (p *Postgres) GetSuppressions(ctx context.Context) ([]*models.Suppression, error) {
+	query := `
+	SELECT id, client_id, phone_number, reason, source, created_at
+	FROM suppression
+	ORDER BY created_at DESC, id DESC
+	`
+	rows, err := p.db.Query(ctx, query)
+	if err != nil {
+		return nil, errors.Wrap(err, "select from suppression")
+	}
+	suppressions, err := pgx.CollectRows(rows, pgx.RowToAddrOfStructByName[models.Suppression])
+	if err != nil {
+		return nil, errors.Wrap(err, "collect rows")
+	}
+	return suppressions, nil
+}
+
+// SaveSuppression adds entry to suppression list.
+// Client or phone number, that is already suppressed, can't be added again.
+func (p *Postgres) SaveSuppression(ctx context.Context, suppression *models.Suppression) error {
+	query := `
+	INSERT INTO suppression(client_id, phone_number, reason, source, created_at)
+	VALUES ($1, $2, $3, $4, $5)
+	RETURNING id
+	`
+	suppression.CreatedAt = time.Now().UTC()
+	err := p.db.QueryRow(ctx, query, suppression.ClientID, suppression.PhoneNumber, suppression.Reason,
+		suppression.Source, suppression.CreatedAt).Scan(&suppression.ID)
+	var pgErr *pgconn.PgError
+	if errors.As(err, &pgErr) && pgErr.Code == _uniqueViolation {
+		return models.ErrAlreadySuppressed
+	}
+	if errors.As(err, &pgErr) && pgErr.Code == _foreignKeyViolation {
+		return models.ErrClientNotFound
+	}
+	if err != nil {
+		return errors.Wrap(err, "insert into suppression")
+	}
+	return nil
+}
+
+// DeleteSuppression deletes entry from suppression list by given id.
+func (p *Postgres) DeleteSuppression(ctx context.Context, id int64) error {
+	query := `
+	DELETE FROM suppression
+	WHERE id = $1
+	`
+	tag, err := p.db.Exec(ctx, query, id)
+	if err != nil {
+		return errors.Wrap(err, "delete from suppression")
+	}
+	if tag.RowsAffected() == 0 {
+		return models.ErrSuppressionNotFound
+	}
+	return nil
+}
+
+// IsClientSuppressed reports whether client matches suppression list by id or phone number.
+func (p *Postgres) IsClientSuppressed(ctx context.Context, client *models.Client) (bool, error) {
+	query := `
+	SELECT EXISTS (SELECT 1 FROM suppression s WHERE s.client_id = $1 OR s.phone_number = $2)
+	`
+	var ok bool
+	err := p.db.QueryRow(ctx, query, client.ID, client.PhoneNumber).Scan(&ok)
+	if err != nil {
+		return false, errors.Wrap(err, "select from suppression")
+	}
+	return ok, nil
+}
+
+// CountSuppressedClients returns the amount of clients in mailing's audience, that are suppressed.
+func (p *Postgres) CountSuppressedClients(ctx context.Context, mailing *models.Mailing) (int, error) {
+	fc, condition, err := p.mailingAudience(ctx, mailing)
+	if err != nil {
+		return 0, err
+	}
+	query := `
+	SELECT COUNT(*)
+	FROM client c
+	WHERE ` + condition + ` AND ` + suppressed
+	var count int
+	err = p.db.QueryRow(ctx, query, fc.args...).Scan(&count)
+	if err != nil {
+		return 0, errors.Wrap(err, "select from client")
+	}
+	return count, nil
+}
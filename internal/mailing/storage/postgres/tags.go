@@ -0,0 +1,94 @@
+package postgres
+
+import (
+	"context"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/pkg/errors"
+
+	"mailing/internal/models"
+)
+
+// GetTags returns names of all tags.
+func (p *Postgres) GetTags(ctx context.Context) ([]string, error) {
+	query := `
+	SELECT name
+	FROM tag
+	ORDER BY name
+	`
+	rows, err := p.db.Query(ctx, query)
+	if err != nil {
+		return nil, errors.Wrap(err, "select from tag")
+	}
+	tags, err := pgx.CollectRows(rows, pgx.RowTo[string])
+	if err != nil {
+		return nil, errors.Wrap(err, "collect rows")
+	}
+	return tags, nil
+}
+
+// UpdateTags adds and removes tags of given clients.
+func (p *Postgres) UpdateTags(ctx context.Context, update *models.TagsUpdate) error {
+	tx, err := p.db.Begin(ctx)
+	if err != nil {
+		return errors.Wrap(err, "begin transaction")
+	}
+	defer tx.Rollback(ctx)
+	err = addTags(ctx, tx, update.ClientIDs, update.Add)
+	if err != nil {
+		return err
+	}
+	err = removeTags(ctx, tx, update.ClientIDs, update.Remove)
+	if err != nil {
+		return err
+	}
+	err = tx.Commit(ctx)
+	if err != nil {
+		return errors.Wrap(err, "commit transaction")
+	}
+	return nil
+}
+
+// addTags adds tags to clients, creating tags that don't exist yet.
+func addTags(ctx context.Context, tx pgx.Tx, clientIDs []int64, tags []string) error {
+	if len(clientIDs) == 0 || len(tags) == 0 {
+		return nil
+	}
+	query := `
+	INSERT INTO tag(name)
+	SELECT unnest($1::varchar[])
+	ON CONFLICT (name) DO NOTHING
+	`
+	_, err := tx.Exec(ctx, query, tags)
+	if err != nil {
+		return errors.Wrap(err, "insert into tag")
+	}
+	query = `
+	INSERT INTO client_tag(client_id, tag_id)
+	SELECT c.id, t.id
+	FROM client c, tag t
+	WHERE c.id = ANY($1) AND t.name = ANY($2)
+	ON CONFLICT DO NOTHING
+	`
+	_, err = tx.Exec(ctx, query, clientIDs, tags)
+	if err != nil {
+		return errors.Wrap(err, "insert into client_tag")
+	}
+	return nil
+}
+
+// removeTags removes tags from clients, tags themselves are kept.
+func removeTags(ctx context.Context, tx pgx.Tx, clientIDs []int64, tags []string) error {
+	if len(clientIDs) == 0 || len(tags) == 0 {
+		return nil
+	}
+	query := `
+	DELETE FROM client_tag
+	WHERE client_id = ANY($1) AND tag_id IN (SELECT id FROM tag WHERE name = ANY($2))
+	`
+	_, err := tx.Exec(ctx, query, clientIDs, tags)
+	if err != nil {
+		return errors.Wrap(err, "delete from client_tag")
+	}
+	return nil
+}
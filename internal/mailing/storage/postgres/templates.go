@@ -0,0 +1,168 @@
+package postgres
+
+import (
+	"context"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
+	"github.com/pkg/errors"
+
+	"mailing/internal/models"
+)
+
+// selectTemplate is a query selecting templates with their latest versions, conditions may be appended to it.
+const selectTemplate = `
+	SELECT t.id, t.name, v.version, v.text, v.created_at AS updated_at
+	FROM template t
+	JOIN LATERAL (
+		SELECT version, text, created_at FROM template_version
+		WHERE template_id = t.id ORDER BY version DESC LIMIT 1
+	) v ON TRUE
+	`
+
+// GetTemplates returns all templates with their latest versions.
+func (p *Postgres) GetTemplates(ctx context.Context) ([]*models.Template, error) {
+	query := selectTemplate + `ORDER BY t.id
+	`
+	rows, err := p.db.Query(ctx, query)
+	if err != nil {
+		return nil, errors.Wrap(err, "select from template")
+	}
+	templates, err := pgx.CollectRows(rows, pgx.RowToAddrOfStructByName[models.Template])
+	if err != nil {
+		return nil, errors.Wrap(err, "collect rows")
+	}
+	return templates, nil
+}
+
+// GetTemplateByID returns template with it's latest version by id.
+func (p *Postgres) GetTemplateByID(ctx context.Context, id int64) (*models.Template, error) {
+	query := selectTemplate + `WHERE t.id = $1
+	`
+	row, err := p.db.Query(ctx, query, id)
+	if err != nil {
+		return nil, errors.Wrap(err, "select from template")
+	}
+	template, err := pgx.CollectOneRow(row, pgx.RowToAddrOfStructByName[models.Template])
+	if errors.Is(err, pgx.ErrNoRows) {
+		return nil, models.ErrTemplateNotFound
+	}
+	if err != nil {
+		return nil, errors.Wrap(err, "collect row")
+	}
+	return template, nil
+}
+
+// GetTemplateVersions returns all versions of template, the latest first.
+func (p *Postgres) GetTemplateVersions(ctx context.Context, id int64) ([]*models.TemplateVersion, error) {
+	query := `
+	SELECT template_id, version, text, created_at
+	FROM template_version
+	WHERE template_id = $1
+	ORDER BY version DESC
+	`
+	rows, err := p.db.Query(ctx, query, id)
+	if err != nil {
+		return nil, errors.Wrap(err, "select from template_version")
+	}
+	versions, err := pgx.CollectRows(rows, pgx.RowToAddrOfStructByName[models.TemplateVersion])
+	if err != nil {
+		return nil, errors.Wrap(err, "collect rows")
+	}
+	return versions, nil
+}
+
+// GetTemplateVersion returns given version of template, version 0 means the latest one.
+func (p *Postgres) GetTemplateVersion(ctx context.Context, id int64, version int) (*models.TemplateVersion, error) {
+	query := `
+	SELECT template_id, version, text, created_at
+	FROM template_version
+	WHERE template_id = $1 AND ($2 = 0 OR version = $2)
+	ORDER BY version DESC
+	LIMIT 1
+	`
+	row, err := p.db.Query(ctx, query, id, version)
+	if err != nil {
+		return nil, errors.Wrap(err, "select from template_version")
+	}
+	v, err := pgx.CollectOneRow(row, pgx.RowToAddrOfStructByName[models.TemplateVersion])
+	if errors.Is(err, pgx.ErrNoRows) {
+		return nil, models.ErrTemplateNotFound
+	}
+	if err != nil {
+		return nil, errors.Wrap(err, "collect row")
+	}
+	return v, nil
+}
+
+// SaveTemplate saves template with it's text as the first version.
+func (p *Postgres) SaveTemplate(ctx context.Context, template *models.Template) error {
+	tx, err := p.db.Begin(ctx)
+	if err != nil {
+		return errors.Wrap(err, "begin transaction")
+	}
+	defer tx.Rollback(ctx)
+	query := `
+	INSERT INTO template(name)
+	VALUES ($1)
+	RETURNING id
+	`
+	err = tx.QueryRow(ctx, query, template.Name).Scan(&template.ID)
+	if err != nil {
+		return errors.Wrap(err, "insert into template")
+	}
+	template.Version = 1
+	template.UpdatedAt = time.Now().UTC()
+	query = `
+	INSERT INTO template_version(template_id, version, text, created_at)
+	VALUES ($1, $2, $3, $4)
+	`
+	_, err = tx.Exec(ctx, query, template.ID, template.Version, template.Text, template.UpdatedAt)
+	if err != nil {
+		return errors.Wrap(err, "insert into template_version")
+	}
+	err = tx.Commit(ctx)
+	if err != nil {
+		return errors.Wrap(err, "commit transaction")
+	}
+	return nil
+}
+
+// AddTemplateVersion saves text as the next version of template.
+func (p *Postgres) AddTemplateVersion(ctx context.Context, id int64, text string) (*models.TemplateVersion, error) {
+	query := `
+	INSERT INTO template_version(template_id, version, text, created_at)
+	SELECT $1, COALESCE(MAX(version), 0) + 1, $2::text, $3::timestamp
+	FROM template_version
+	WHERE template_id = $1
+	RETURNING version
+	`
+	v := &models.TemplateVersion{
+		TemplateID: id,
+		Text:       text,
+		CreatedAt:  time.Now().UTC(),
+	}
+	err := p.db.QueryRow(ctx, query, id, text, v.CreatedAt).Scan(&v.Version)
+	var pgErr *pgconn.PgError
+	if errors.As(err, &pgErr) && pgErr.Code == _foreignKeyViolation {
+		return nil, models.ErrTemplateNotFound
+	}
+	if err != nil {
+		return nil, errors.Wrap(err, "insert into template_version")
+	}
+	return v, nil
+}
+
+// DeleteTemplate deletes template with all it's versions, mailings keep their copies of text.
+func (p *Postgres) DeleteTemplate(ctx context.Context, id int64) error {
+	query := `
+	DELETE FROM template
+	WHERE id = $1
+	`
+	_, err := p.db.Exec(ctx, query, id)
+	if err != nil {
+		return errors.Wrap(err, "delete from template")
+	}
+	return nil
+}
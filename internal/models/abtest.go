@@ -0,0 +1,77 @@
+package models
+
+import (
+	"time"
+)
+
+// ABTestMetric is the rate winner of A/B test is chosen by.
+type ABTestMetric string
+
+const (
+	// ABTestMetricDelivery chooses variant with the highest share of successfully sent messages.
+	ABTestMetricDelivery ABTestMetric = "delivery"
+	// ABTestMetricClick chooses variant with the highest share of sent messages, that were clicked.
+	ABTestMetricClick ABTestMetric = "click"
+)
+
+// ABTest is A/B test of mailing's text variants. Each variant is sent to it's test cell first,
+// then after Wait the winner is sent to the rest of the audience.
+// Variants are numbered from 1, variant 1 is mailing's text and the rest are Variants in order.
+type ABTest struct {
+	// Variants are alternative texts competing with mailing's text.
+	Variants []string `json:"variants"`
+	// CellPercent is the percentage of audience in test cell of each variant.
+	CellPercent int `json:"cellPercent"`
+	// Wait is the time between the end of test and rollout of the winner.
+	Wait   time.Duration `json:"wait"`
+	Metric ABTestMetric  `json:"metric"`
+	// Winner is the number of winning variant, 0 until test is finished.
+	Winner int `json:"winner"`
+	// RolloutAt is the time the winner is chosen and sent to the rest of audience at, zero until test is sent.
+	RolloutAt time.Time `json:"rolloutAt"`
+}
+
+// Texts returns texts of all variants of A/B test of mailing, text of variant n is at index n-1.
+func (m *Mailing) Texts() []string {
+	if m.ABTest == nil {
+		return []string{m.Text}
+	}
+	return append([]string{m.Text}, m.ABTest.Variants...)
+}
+
+// TestCell returns the number of variant, that client is sent in test stage of A/B test,
+// 0 if client belongs to the rest of audience. Like HeldOut, it depends only on mailing's and client's ids,
+// but test cells are independent of holdout group.
+func (m *Mailing) TestCell(clientID int64) int {
+	if m.ABTest == nil || m.ABTest.CellPercent <= 0 {
+		return 0
+	}
+	variant := int(m.bucket("ab", clientID))/m.ABTest.CellPercent + 1
+	if variant > len(m.ABTest.Variants)+1 {
+		return 0
+	}
+	return variant
+}
+
+// VariantStats is statistic of A/B test variant.
+type VariantStats struct {
+	Variant  int `db:"variant"`
+	Messages int `db:"messages"`
+	Success  int `db:"success"`
+	Fails    int `db:"fails"`
+	Clicked  int `db:"clicked"`
+}
+
+// Rate returns the rate of variant by given metric.
+func (s *VariantStats) Rate(metric ABTestMetric) float64 {
+	if metric == ABTestMetricClick {
+		if s.Success == 0 {
+			return 0
+		}
+		return float64(s.Clicked) / float64(s.Success)
+	}
+	if s.Messages == 0 {
+		return 0
+	}
+	return float64(s.Success) / float64(s.Messages)
+}
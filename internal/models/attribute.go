@@ -0,0 +1,149 @@
+package models
+
+import (
+	"encoding/json"
+	"regexp"
+	"slices"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+// ErrAttributeTypeConflict is returned when attribute is redefined with another type.
+var ErrAttributeTypeConflict = errors.New("attribute already exists with another type")
+
+// ErrAttributeInUse is returned when attribute can't be deleted, because filters or texts reference it.
+var ErrAttributeInUse = errors.New("attribute is used by filters or texts")
+
+// AttributeDefinition describes custom client's attribute.
+type AttributeDefinition struct {
+	// Name is the key of attribute in client's attributes.
+	Name string `db:"name"`
+	// Type is the type of attribute's values.
+	Type AttributeType `db:"type"`
+	// Required attribute must be set when client is created and can't be removed later.
+	Required bool `db:"required"`
+	// AllowedValues restricts values of string attribute, empty means any value is allowed.
+	AllowedValues []string `db:"allowed_values"`
+}
+
+type AttributeType string
+
+const (
+	// AttributeTypeString is type of text attributes, e.g. name or city.
+	AttributeTypeString AttributeType = "string"
+	// AttributeTypeNumber is type of numeric attributes.
+	AttributeTypeNumber AttributeType = "number"
+	// AttributeTypeDate is type of date attributes, e.g. birthday, formatted as AttributeDateLayout.
+	AttributeTypeDate AttributeType = "date"
+	// AttributeTypeBoolean is type of yes/no attributes.
+	AttributeTypeBoolean AttributeType = "boolean"
+)
+
+// AttributeDateLayout is the format of date attribute values.
+const AttributeDateLayout = "2006-01-02"
+
+var attributeNameRegexp = regexp.MustCompile(`^[a-zA-Z_][a-zA-Z0-9_]{0,99}$`)
+
+// Validate checks that attribute definition is well-formed.
+func (d *AttributeDefinition) Validate() error {
+	if !attributeNameRegexp.MatchString(d.Name) {
+		return errors.Errorf("attribute name %q must consist of latin letters, digits and underscores", d.Name)
+	}
+	switch d.Type {
+	case AttributeTypeString, AttributeTypeNumber, AttributeTypeDate, AttributeTypeBoolean:
+	default:
+		return errors.Errorf("unknown attribute type %q", d.Type)
+	}
+	if len(d.AllowedValues) > 0 && d.Type != AttributeTypeString {
+		return errors.New("allowed values are applicable only to string attributes")
+	}
+	return nil
+}
+
+// ParseValue checks that value conforms to attribute definition and converts it to attribute's type.
+// Dates are kept as strings, so values can be stored as json.
+func (d *AttributeDefinition) ParseValue(v any) (any, error) {
+	switch d.Type {
+	case AttributeTypeString:
+		s, ok := v.(string)
+		if !ok {
+			return nil, errors.Errorf("attribute %s must be a string", d.Name)
+		}
+		if len(d.AllowedValues) > 0 && !slices.Contains(d.AllowedValues, s) {
+			return nil, errors.Errorf("attribute %s must be one of %v", d.Name, d.AllowedValues)
+		}
+		return s, nil
+	case AttributeTypeNumber:
+		switch n := v.(type) {
+		case float64:
+			return n, nil
+		case int:
+			return float64(n), nil
+		case int64:
+			return float64(n), nil
+		case json.Number:
+			f, err := n.Float64()
+			if err != nil {
+				return nil, errors.Errorf("attribute %s must be a number", d.Name)
+			}
+			return f, nil
+		}
+		return nil, errors.Errorf("attribute %s must be a number", d.Name)
+	case AttributeTypeDate:
+		s, ok := v.(string)
+		if !ok {
+			return nil, errors.Errorf("attribute %s must be a date formatted as %s", d.Name, AttributeDateLayout)
+		}
+		date, err := time.Parse(AttributeDateLayout, s)
+		if err != nil {
+			return nil, errors.Errorf("attribute %s must be a date formatted as %s", d.Name, AttributeDateLayout)
+		}
+		return date.Format(AttributeDateLayout), nil
+	case AttributeTypeBoolean:
+		b, ok := v.(bool)
+		if !ok {
+			return nil, errors.Errorf("attribute %s must be a boolean", d.Name)
+		}
+		return b, nil
+	}
+	return nil, errors.Errorf("unknown attribute type %q", d.Type)
+}
+
+// ValidateAttributes checks client's attributes against definitions and converts them to attribute's types.
+// Partial attributes are an update, so required attributes may be absent, but can't be removed with null.
+func ValidateAttributes(attributes map[string]any, definitions []*AttributeDefinition, partial bool) error {
+	byName := map[string]*AttributeDefinition{}
+	for _, d := range definitions {
+		byName[d.Name] = d
+	}
+	for name, v := range attributes {
+		d, ok := byName[name]
+		if !ok {
+			return errors.Errorf("unknown attribute %q", name)
+		}
+		if v == nil {
+			if d.Required {
+				return errors.Errorf("attribute %s is required", name)
+			}
+			if !partial {
+				delete(attributes, name)
+			}
+			continue
+		}
+		parsed, err := d.ParseValue(v)
+		if err != nil {
+			return err
+		}
+		attributes[name] = parsed
+	}
+	if partial {
+		return nil
+	}
+	for _, d := range definitions {
+		if _, ok := attributes[d.Name]; d.Required && !ok {
+			return errors.Errorf("attribute %s is required", d.Name)
+		}
+	}
+	return nil
+}
@@ -1,18 +1,38 @@
 package models
 
+import "github.com/pkg/errors"
+
+// ErrClientNotFound is returned when client with given id doesn't exist.
+var ErrClientNotFound = errors.New("client not found")
+
 // Client is a struct that represents client.
 type Client struct {
-	ID            int64  `db:"id"`
-	PhoneNumber   int64  `db:"phone_number"`
-	PhoneOperator int    `db:"phone_operator"`
-	Tag           string `db:"tag"`
-	Timezone      string `db:"timezone"`
+	ID            int64    `db:"id"`
+	PhoneNumber   int64    `db:"phone_number"`
+	PhoneOperator int      `db:"phone_operator"`
+	Tags          []string `db:"tags"`
+	Timezone      string   `db:"timezone"`
+	// Attributes are custom attributes by name, see AttributeDefinition.
+	Attributes map[string]any `db:"attributes"`
+	// Locale is client's preferred locale, e.g. "en-us", empty if client has no preference.
+	Locale string `db:"locale"`
 }
 
 // ClientUpdate is a struct with updates which should be applied to client.
 type ClientUpdate struct {
 	PhoneNumber   int64
 	PhoneOperator int
-	Tag           string
-	Timezone      string
+	// Tags replace all client's tags, nil means no change.
+	Tags     []string
+	Timezone string
+	// Attributes are merged into client's attributes, null value removes attribute.
+	Attributes map[string]any
+	Locale     string
+}
+
+// TagsUpdate is a struct with tags which should be added to and removed from clients.
+type TagsUpdate struct {
+	ClientIDs []int64
+	Add       []string
+	Remove    []string
 }
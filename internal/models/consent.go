@@ -0,0 +1,52 @@
+package models
+
+import (
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+// ErrConsentNotFound is returned when there is no consent record with given token.
+var ErrConsentNotFound = errors.New("consent not found")
+
+// ErrConsentNotPending is returned when confirmed consent was already confirmed or superseded by another record.
+var ErrConsentNotPending = errors.New("consent is not pending")
+
+// ErrConsentExpired is returned when double opt-in is confirmed too late.
+var ErrConsentExpired = errors.New("consent request expired")
+
+// ConsentChannelSMS is the channel of SMS messages, the only one service sends to.
+const ConsentChannelSMS = "sms"
+
+// ConsentStatus is the state of client's consent.
+type ConsentStatus string
+
+const (
+	// ConsentStatusPending is the status of double opt-in, that waits for client's confirmation.
+	ConsentStatusPending ConsentStatus = "pending"
+	// ConsentStatusGranted is the status of client, that agreed to receive messages.
+	ConsentStatusGranted ConsentStatus = "granted"
+	// ConsentStatusRevoked is the status of client, that withdrew consent.
+	ConsentStatusRevoked ConsentStatus = "revoked"
+)
+
+const (
+	// ConsentSourceDoubleOptIn is the source of consents confirmed by client's reply or link click.
+	ConsentSourceDoubleOptIn = "double opt-in"
+)
+
+// Consent is a record of client's consent to receive messages in channel.
+// Records are never changed, the latest one is the current consent of client in channel.
+type Consent struct {
+	ID       int64         `db:"id"`
+	ClientID int64         `db:"client_id"`
+	Channel  string        `db:"channel"`
+	Status   ConsentStatus `db:"status"`
+	// Source tells where consent came from, e.g. ConsentSourceDoubleOptIn or "signup form".
+	Source string `db:"source"`
+	// Proof is an evidence of consent, e.g. id of client's reply or signed form number.
+	Proof string `db:"proof"`
+	// Token identifies pending consent in confirmation link, empty for other statuses.
+	Token     string    `db:"token"`
+	CreatedAt time.Time `db:"created_at"`
+}
@@ -0,0 +1,30 @@
+package models
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+type ConversationDirection string
+
+const (
+	// ConversationDirectionOutbound is the direction of messages sent to client.
+	ConversationDirectionOutbound ConversationDirection = "outbound"
+	// ConversationDirectionInbound is the direction of messages received from client.
+	ConversationDirectionInbound ConversationDirection = "inbound"
+)
+
+// ConversationEntry is a message sent to or received from client, see Message and InboundMessage.
+type ConversationEntry struct {
+	Direction ConversationDirection `db:"direction"`
+	// ID is the id of message or inbound message depending on direction.
+	ID   int64     `db:"id"`
+	Text string    `db:"text"`
+	Time time.Time `db:"time"`
+	// Status and MailingID are set only for outbound messages, MailingID is nil for standalone ones.
+	Status    *SendStatus `db:"status"`
+	MailingID *uuid.UUID  `db:"mailing_id"`
+	// ReplyTo is set only for inbound messages, see InboundMessage.
+	ReplyTo *int64 `db:"reply_to"`
+}
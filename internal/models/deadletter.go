@@ -0,0 +1,32 @@
+package models
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// DeadLetterReplay is a request to resend failed messages.
+type DeadLetterReplay struct {
+	// MessageIDs are ids of failed messages to be resent.
+	MessageIDs []int64
+	// MailingID, if set, selects all failed messages of the mailing to be resent.
+	MailingID *uuid.UUID
+	// EndTime, if set, overrides mailing's end time for the replay.
+	EndTime time.Time
+}
+
+// ReplayResult is a result of dead letters replay.
+type ReplayResult struct {
+	// Replayed is the amount of messages, that tried to resend.
+	Replayed int
+	// Succeeded is the amount of messages, that were resent successfully.
+	Succeeded int
+	// Failed is the amount of messages, that failed again.
+	Failed int
+	// Expired is the amount of messages, that became stale before they were resent.
+	Expired int
+	// Skipped is the amount of messages, that were not resent, e.g. because mailing's end time is exceeded,
+	// because of frequency caps or because they don't fit into mailing's budget.
+	Skipped int
+}
@@ -1,8 +1,299 @@
 package models
 
-// Filter is a filter to be applied when searching for client's.
+import (
+	"encoding/json"
+	"math"
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+// Filter is a filter expression to be applied when searching for client's.
+// Nil filter matches any client.
 type Filter struct {
-	PhoneOperator int
-	Tag           string
-	Timezone      string
+	// Op is the operator of expression.
+	Op FilterOp `json:"op"`
+	// Field is the client's field checked by comparison operators.
+	Field string `json:"field,omitempty"`
+	// Value is the operand of eq, prefix and comparison operators.
+	Value any `json:"value,omitempty"`
+	// Values are the operands of in operator.
+	Values []any `json:"values,omitempty"`
+	// From and To are inclusive bounds of range operator, nil bound is open.
+	From any `json:"from,omitempty"`
+	To   any `json:"to,omitempty"`
+	// Args are the operands of and, or and not operators.
+	Args []*Filter `json:"args,omitempty"`
+}
+
+type FilterOp string
+
+const (
+	// FilterOpAnd matches if all of it's args match, and without args matches any client.
+	FilterOpAnd FilterOp = "and"
+	// FilterOpOr matches if any of it's args matches, or without args matches no client.
+	FilterOpOr FilterOp = "or"
+	// FilterOpNot matches if it's only arg doesn't match.
+	FilterOpNot FilterOp = "not"
+	// FilterOpEq matches if field is equal to value.
+	FilterOpEq FilterOp = "eq"
+	// FilterOpIn matches if field is equal to any of values.
+	FilterOpIn FilterOp = "in"
+	// FilterOpPrefix matches if field starts with value, e.g. phone number with country and operator code.
+	FilterOpPrefix FilterOp = "prefix"
+	// FilterOpRange matches if field is between from and to.
+	FilterOpRange FilterOp = "range"
+	// FilterOpAll matches if multi-valued field contains all of values, e.g. client has all of given tags.
+	FilterOpAll FilterOp = "all"
+	// FilterOpLt, FilterOpLte, FilterOpGt and FilterOpGte match if field is less, less or equal,
+	// greater, greater or equal than value.
+	FilterOpLt  FilterOp = "lt"
+	FilterOpLte FilterOp = "lte"
+	FilterOpGt  FilterOp = "gt"
+	FilterOpGte FilterOp = "gte"
+)
+
+const (
+	// FilterFieldPhoneNumber is client's phone number.
+	FilterFieldPhoneNumber = "phoneNumber"
+	// FilterFieldPhoneOperator is client's phone operator code.
+	FilterFieldPhoneOperator = "phoneOperator"
+	// FilterFieldTag is client's tag. Client has many tags, so comparison matches if any of them matches.
+	FilterFieldTag = "tag"
+	// FilterFieldTimezone is client's timezone.
+	FilterFieldTimezone = "timezone"
+	// FilterFieldAttributePrefix prefixes name of client's custom attribute, e.g. "attr.birthday".
+	// Operands of attribute fields are checked against attribute definition when filter is compiled.
+	FilterFieldAttributePrefix = "attr."
+)
+
+// _filterMaxDepth limits nesting of filter expression.
+const _filterMaxDepth = 32
+
+// filterFieldNumeric tells whether values of filter field are numbers or strings.
+var filterFieldNumeric = map[string]bool{
+	FilterFieldPhoneNumber:   true,
+	FilterFieldPhoneOperator: true,
+	FilterFieldTag:           false,
+	FilterFieldTimezone:      false,
+}
+
+// NewFlatFilter returns filter matching clients with all given attributes, zero attribute means "any".
+func NewFlatFilter(phoneOperator int, tag string, timezone string) *Filter {
+	f := &Filter{Op: FilterOpAnd, Args: []*Filter{}}
+	if phoneOperator != 0 {
+		f.Args = append(f.Args, &Filter{Op: FilterOpEq, Field: FilterFieldPhoneOperator, Value: int64(phoneOperator)})
+	}
+	if tag != "" {
+		f.Args = append(f.Args, &Filter{Op: FilterOpEq, Field: FilterFieldTag, Value: tag})
+	}
+	if timezone != "" {
+		f.Args = append(f.Args, &Filter{Op: FilterOpEq, Field: FilterFieldTimezone, Value: timezone})
+	}
+	return f
+}
+
+// Validate checks that filter expression is well-formed and converts it's operands to types of fields.
+// Nil filter is valid.
+func (f *Filter) Validate() error {
+	return f.validate(0)
+}
+
+func (f *Filter) validate(depth int) error {
+	if f == nil {
+		return nil
+	}
+	if depth > _filterMaxDepth {
+		return errors.New("filter is nested too deep")
+	}
+	switch f.Op {
+	case FilterOpAnd, FilterOpOr, FilterOpNot:
+		if f.Op == FilterOpNot && len(f.Args) != 1 {
+			return errors.New("not must have exactly one arg")
+		}
+		for _, arg := range f.Args {
+			if arg == nil {
+				return errors.Errorf("%s has empty arg", f.Op)
+			}
+			err := arg.validate(depth + 1)
+			if err != nil {
+				return err
+			}
+		}
+		return nil
+	case FilterOpEq, FilterOpIn, FilterOpPrefix, FilterOpRange, FilterOpLt, FilterOpLte, FilterOpGt, FilterOpGte:
+	case FilterOpAll:
+		if f.Field != FilterFieldTag {
+			return errors.Errorf("all is not applicable to %s", f.Field)
+		}
+	default:
+		return errors.Errorf("unknown filter operator %q", f.Op)
+	}
+
+	if _, ok := f.Attribute(); ok {
+		return f.validateAttribute()
+	}
+	numeric, ok := filterFieldNumeric[f.Field]
+	if !ok {
+		return errors.Errorf("unknown filter field %q", f.Field)
+	}
+	var err error
+	switch f.Op {
+	case FilterOpEq, FilterOpLt, FilterOpLte, FilterOpGt, FilterOpGte:
+		f.Value, err = filterValue(f.Field, numeric, f.Value)
+	case FilterOpPrefix:
+		// Prefix is matched against text representation of field, so numbers are allowed too.
+		f.Value, err = filterValue(f.Field, numeric, f.Value)
+		if err == nil && !numeric && f.Value == "" {
+			err = errors.New("prefix must not be empty")
+		}
+	case FilterOpIn, FilterOpAll:
+		for i := range f.Values {
+			f.Values[i], err = filterValue(f.Field, numeric, f.Values[i])
+			if err != nil {
+				break
+			}
+		}
+	case FilterOpRange:
+		if f.From == nil && f.To == nil {
+			return errors.New("range must have at least one bound")
+		}
+		if f.From != nil {
+			f.From, err = filterValue(f.Field, numeric, f.From)
+		}
+		if err == nil && f.To != nil {
+			f.To, err = filterValue(f.Field, numeric, f.To)
+		}
+	}
+	return err
+}
+
+// Attribute returns name of custom attribute if filter compares one.
+func (f *Filter) Attribute() (string, bool) {
+	name, ok := strings.CutPrefix(f.Field, FilterFieldAttributePrefix)
+	return name, ok && name != ""
+}
+
+// validateAttribute checks operands of comparison on custom attribute, their types are checked against definition later.
+func (f *Filter) validateAttribute() error {
+	check := func(v any) error {
+		switch v.(type) {
+		case string, bool, float64, int, int64, json.Number:
+			return nil
+		}
+		return errors.Errorf("value of %s must be a string, number or boolean", f.Field)
+	}
+	switch f.Op {
+	case FilterOpEq, FilterOpPrefix, FilterOpLt, FilterOpLte, FilterOpGt, FilterOpGte:
+		return check(f.Value)
+	case FilterOpIn:
+		for _, v := range f.Values {
+			if err := check(v); err != nil {
+				return err
+			}
+		}
+	case FilterOpRange:
+		if f.From == nil && f.To == nil {
+			return errors.New("range must have at least one bound")
+		}
+		for _, v := range []any{f.From, f.To} {
+			if v == nil {
+				continue
+			}
+			if err := check(v); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// ValidateAttributes checks that custom attributes compared by filter are defined and operands match their types.
+func (f *Filter) ValidateAttributes(definitions []*AttributeDefinition) error {
+	byName := map[string]*AttributeDefinition{}
+	for _, d := range definitions {
+		byName[d.Name] = d
+	}
+	return f.Walk(func(f *Filter) error {
+		name, ok := f.Attribute()
+		if !ok {
+			return nil
+		}
+		d, ok := byName[name]
+		if !ok {
+			return errors.Errorf("unknown attribute %q", name)
+		}
+		if f.Op == FilterOpPrefix {
+			// Prefix is matched against text representation of attribute.
+			return nil
+		}
+		for _, v := range append([]any{f.Value, f.From, f.To}, f.Values...) {
+			if v == nil {
+				continue
+			}
+			if _, err := d.ParseValue(v); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+// References reports whether filter or any of it's nested args compares given custom attribute.
+func (f *Filter) References(attribute string) bool {
+	errFound := errors.New("found")
+	return f.Walk(func(f *Filter) error {
+		if name, ok := f.Attribute(); ok && name == attribute {
+			return errFound
+		}
+		return nil
+	}) != nil
+}
+
+// Walk calls fn for filter and all of it's nested args.
+func (f *Filter) Walk(fn func(*Filter) error) error {
+	if f == nil {
+		return nil
+	}
+	err := fn(f)
+	if err != nil {
+		return err
+	}
+	for _, arg := range f.Args {
+		err = arg.Walk(fn)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// filterValue converts operand to type of given field.
+func filterValue(field string, numeric bool, v any) (any, error) {
+	if !numeric {
+		s, ok := v.(string)
+		if !ok {
+			return nil, errors.Errorf("value of %s must be a string", field)
+		}
+		return s, nil
+	}
+	switch n := v.(type) {
+	case int:
+		return int64(n), nil
+	case int64:
+		return n, nil
+	case float64:
+		if n != math.Trunc(n) {
+			return nil, errors.Errorf("value of %s must be an integer", field)
+		}
+		return int64(n), nil
+	case json.Number:
+		i, err := n.Int64()
+		if err != nil {
+			return nil, errors.Errorf("value of %s must be an integer", field)
+		}
+		return i, nil
+	default:
+		return nil, errors.Errorf("value of %s must be a number", field)
+	}
 }
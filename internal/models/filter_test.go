@@ -0,0 +1,161 @@
+package models
+
+import (
+	"encoding/json"
+	"reflect"
+	"testing"
+)
+
+func TestFilterValidate(t *testing.T) {
+	nested := &Filter{Op: FilterOpEq, Field: FilterFieldTag, Value: "vip"}
+	for i := 0; i <= _filterMaxDepth; i++ {
+		nested = &Filter{Op: FilterOpNot, Args: []*Filter{nested}}
+	}
+	tests := []struct {
+		name    string
+		filter  *Filter
+		want    *Filter
+		wantErr bool
+	}{
+		{name: "nil", filter: nil, want: nil},
+		{name: "empty and", filter: &Filter{Op: FilterOpAnd}, want: &Filter{Op: FilterOpAnd}},
+		{
+			name:   "number from JSON",
+			filter: &Filter{Op: FilterOpEq, Field: FilterFieldPhoneOperator, Value: float64(900)},
+			want:   &Filter{Op: FilterOpEq, Field: FilterFieldPhoneOperator, Value: int64(900)},
+		},
+		{
+			name:   "json number",
+			filter: &Filter{Op: FilterOpGte, Field: FilterFieldPhoneNumber, Value: json.Number("79990000000")},
+			want:   &Filter{Op: FilterOpGte, Field: FilterFieldPhoneNumber, Value: int64(79990000000)},
+		},
+		{
+			name:    "fractional number",
+			filter:  &Filter{Op: FilterOpEq, Field: FilterFieldPhoneOperator, Value: 900.5},
+			wantErr: true,
+		},
+		{
+			name:    "string for numeric field",
+			filter:  &Filter{Op: FilterOpEq, Field: FilterFieldPhoneOperator, Value: "900"},
+			wantErr: true,
+		},
+		{
+			name:    "number for string field",
+			filter:  &Filter{Op: FilterOpEq, Field: FilterFieldTimezone, Value: float64(3)},
+			wantErr: true,
+		},
+		{
+			name:   "numeric prefix",
+			filter: &Filter{Op: FilterOpPrefix, Field: FilterFieldPhoneNumber, Value: float64(7999)},
+			want:   &Filter{Op: FilterOpPrefix, Field: FilterFieldPhoneNumber, Value: int64(7999)},
+		},
+		{
+			name:    "empty prefix",
+			filter:  &Filter{Op: FilterOpPrefix, Field: FilterFieldTag, Value: ""},
+			wantErr: true,
+		},
+		{
+			name:   "in",
+			filter: &Filter{Op: FilterOpIn, Field: FilterFieldPhoneOperator, Values: []any{float64(900), 901}},
+			want:   &Filter{Op: FilterOpIn, Field: FilterFieldPhoneOperator, Values: []any{int64(900), int64(901)}},
+		},
+		{
+			name:    "in with wrong type",
+			filter:  &Filter{Op: FilterOpIn, Field: FilterFieldTag, Values: []any{"a", float64(1)}},
+			wantErr: true,
+		},
+		{
+			name:   "open range",
+			filter: &Filter{Op: FilterOpRange, Field: FilterFieldPhoneOperator, To: float64(950)},
+			want:   &Filter{Op: FilterOpRange, Field: FilterFieldPhoneOperator, To: int64(950)},
+		},
+		{
+			name:    "range without bounds",
+			filter:  &Filter{Op: FilterOpRange, Field: FilterFieldPhoneOperator},
+			wantErr: true,
+		},
+		{
+			name:   "all tags",
+			filter: &Filter{Op: FilterOpAll, Field: FilterFieldTag, Values: []any{"a", "b"}},
+			want:   &Filter{Op: FilterOpAll, Field: FilterFieldTag, Values: []any{"a", "b"}},
+		},
+		{
+			name:    "all on single-valued field",
+			filter:  &Filter{Op: FilterOpAll, Field: FilterFieldTimezone, Values: []any{"UTC"}},
+			wantErr: true,
+		},
+		{
+			name:    "not with two args",
+			filter:  &Filter{Op: FilterOpNot, Args: []*Filter{{Op: FilterOpAnd}, {Op: FilterOpAnd}}},
+			wantErr: true,
+		},
+		{
+			name:    "nil arg",
+			filter:  &Filter{Op: FilterOpOr, Args: []*Filter{nil}},
+			wantErr: true,
+		},
+		{
+			name:    "invalid nested arg",
+			filter:  &Filter{Op: FilterOpAnd, Args: []*Filter{{Op: FilterOpEq, Field: "unknown", Value: "x"}}},
+			wantErr: true,
+		},
+		{name: "unknown operator", filter: &Filter{Op: "like", Field: FilterFieldTag, Value: "a"}, wantErr: true},
+		{name: "too deep", filter: nested, wantErr: true},
+		{
+			name:   "attribute",
+			filter: &Filter{Op: FilterOpEq, Field: "attr.vip", Value: true},
+			want:   &Filter{Op: FilterOpEq, Field: "attr.vip", Value: true},
+		},
+		{
+			name:    "attribute with object value",
+			filter:  &Filter{Op: FilterOpEq, Field: "attr.vip", Value: map[string]any{}},
+			wantErr: true,
+		},
+		{
+			name:    "attribute without name",
+			filter:  &Filter{Op: FilterOpEq, Field: "attr.", Value: "x"},
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := tt.filter.Validate()
+			if tt.wantErr {
+				if err == nil {
+					t.Fatal("Validate() succeeded, want error")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Validate() error = %v", err)
+			}
+			if !reflect.DeepEqual(tt.filter, tt.want) {
+				t.Errorf("Validate() converted filter to %+v, want %+v", tt.filter, tt.want)
+			}
+		})
+	}
+}
+
+func TestFilterReferences(t *testing.T) {
+	city := &Filter{Op: FilterOpEq, Field: FilterFieldAttributePrefix + "city", Value: "Moscow"}
+	tag := &Filter{Op: FilterOpEq, Field: FilterFieldTag, Value: "vip"}
+	tests := []struct {
+		name   string
+		filter *Filter
+		want   bool
+	}{
+		{name: "nil filter", filter: nil, want: false},
+		{name: "attribute", filter: city, want: true},
+		{name: "other field", filter: tag, want: false},
+		{name: "other attribute", filter: &Filter{Op: FilterOpEq, Field: FilterFieldAttributePrefix + "cityName", Value: "x"}, want: false},
+		{name: "nested attribute", filter: &Filter{Op: FilterOpOr, Args: []*Filter{tag, {Op: FilterOpNot, Args: []*Filter{city}}}}, want: true},
+		{name: "no nested attribute", filter: &Filter{Op: FilterOpAnd, Args: []*Filter{tag, tag}}, want: false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.filter.References("city"); got != tt.want {
+				t.Errorf("References(city) = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
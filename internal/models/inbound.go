@@ -0,0 +1,17 @@
+package models
+
+import "time"
+
+// InboundMessage is a message received from client's phone, e.g. a reply to mailing.
+type InboundMessage struct {
+	ID          int64 `db:"id"`
+	PhoneNumber int64 `db:"phone_number"`
+	// ClientID is the id of client with the phone number, nil if phone number is unknown.
+	ClientID *int64 `db:"client_id"`
+	// ReplyTo is the id of the last message sent to client before it was received, nil if there was none.
+	ReplyTo    *int64    `db:"reply_to"`
+	Text       string    `db:"text"`
+	ReceivedAt time.Time `db:"received_at"`
+	// Stop tells whether text matched one of STOP keywords, so the phone number was suppressed.
+	Stop bool `db:"stop"`
+}
@@ -0,0 +1,32 @@
+package models
+
+import "github.com/pkg/errors"
+
+// ErrLinkNotFound is returned when there is no short link with given code.
+var ErrLinkNotFound = errors.New("link not found")
+
+// Link is a short link replacing URL in message's text, clicks on it are counted against the message.
+type Link struct {
+	Code      string `db:"code"`
+	MessageID int64  `db:"message_id"`
+	URL       string `db:"url"`
+	Clicks    int    `db:"clicks"`
+}
+
+// ClickStats is a statistic of clicks on short links of mailing's messages.
+type ClickStats struct {
+	// Delivered is the amount of messages sent successfully.
+	Delivered int `db:"delivered"`
+	// Clicked is the amount of messages with at least one click on their links.
+	Clicked int `db:"clicked"`
+	// Clicks is the total amount of clicks, repeated ones included.
+	Clicks int `db:"clicks"`
+}
+
+// ClickThroughRate returns the share of delivered messages that were clicked, 0 if nothing was delivered.
+func (s *ClickStats) ClickThroughRate() float64 {
+	if s.Delivered == 0 {
+		return 0
+	}
+	return float64(s.Clicked) / float64(s.Delivered)
+}
@@ -0,0 +1,45 @@
+package models
+
+import (
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+var localeRegexp = regexp.MustCompile(`^[a-z]{2,3}(-[a-z0-9]{2,8})*$`)
+
+// NormalizeLocale returns locale in lower case with hyphen separators, e.g. "en_US" becomes "en-us".
+// Empty locale is valid and means no preference.
+func NormalizeLocale(locale string) (string, error) {
+	locale = strings.ToLower(strings.ReplaceAll(strings.TrimSpace(locale), "_", "-"))
+	if locale != "" && (len(locale) > 35 || !localeRegexp.MatchString(locale)) {
+		return "", errors.Errorf("invalid locale %q", locale)
+	}
+	return locale, nil
+}
+
+// MatchLocale returns the best match for normalized locale among available ones, empty if none matches.
+// Exact match is preferred, then variant of the same language without region, then any of the same language.
+func MatchLocale(locale string, available []string) string {
+	if locale == "" {
+		return ""
+	}
+	language, _, _ := strings.Cut(locale, "-")
+	sameLanguage := []string{}
+	for _, a := range available {
+		if a == locale {
+			return a
+		}
+		if l, _, _ := strings.Cut(a, "-"); l == language {
+			sameLanguage = append(sameLanguage, a)
+		}
+	}
+	if len(sameLanguage) == 0 {
+		return ""
+	}
+	sort.Strings(sameLanguage)
+	// Language without region is the shortest, so it comes first.
+	return sameLanguage[0]
+}
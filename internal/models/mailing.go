@@ -1,9 +1,13 @@
 package models
 
 import (
+	"encoding/binary"
+	"hash/fnv"
+	"sort"
 	"time"
 
 	"github.com/google/uuid"
+	"github.com/pkg/errors"
 )
 
 // Mailing is a struct that represents mailing object.
@@ -14,8 +18,117 @@ type Mailing struct {
 	StartTime time.Time     `db:"start_time"`
 	EndTime   time.Time     `db:"end_time"`
 	Status    MailingStatus `db:"status"`
+	// TTL is the time after which mailing's messages are stale and should not be sent, 0 if they never expire.
+	TTL time.Duration `db:"ttl"`
+	// SegmentID is the id of segment mailing is sent to, nil if mailing is sent to any client matching filter.
+	// Clients must match both filter and segment.
+	SegmentID *int64 `db:"segment_id"`
+	// AudienceMode tells when mailing's audience is resolved.
+	AudienceMode AudienceMode `db:"audience_mode"`
+	// TemplateID and TemplateVersion reference template version text was copied from, nil if text was given as is.
+	TemplateID      *int64 `db:"template_id"`
+	TemplateVersion int    `db:"template_version"`
+	// Variants are localized texts by normalized locale, Text is sent to clients no variant matches.
+	Variants map[string]string `db:"variants"`
+	// MaxSegments is the maximum amount of SMS segments text and each of variants may take, 0 means no limit.
+	MaxSegments int `db:"max_segments"`
+	// RequireConsent restricts audience to clients, that granted consent to SMS, see Consent.
+	RequireConsent bool `db:"require_consent"`
+	// HoldoutPercent is the percentage of matched clients held out as control group, that isn't sent to.
+	HoldoutPercent int `db:"holdout_percent"`
+	// ABTest is A/B test of mailing's text, nil if mailing's text is sent to the whole audience.
+	ABTest *ABTest `db:"ab_test"`
+	// SamplePercent is the percentage of matched clients mailing is sent to, 0 means the whole audience.
+	SamplePercent int `db:"sample_percent"`
+	// MaxRecipients is the maximum amount of matched clients mailing is sent to, 0 means no limit.
+	MaxRecipients int `db:"max_recipients"`
+	// SampleSeed makes random sample reproducible, sample is seeded by mailing's id if it is 0.
+	SampleSeed int64 `db:"sample_seed"`
+	// Budget is the maximum total cost of mailing's messages in minor currency units, 0 means no limit.
+	Budget int64 `db:"budget"`
 }
 
+// HeldOut reports whether client belongs to mailing's holdout group.
+// Membership depends only on mailing's and client's ids, so it is the same in every run.
+func (m *Mailing) HeldOut(clientID int64) bool {
+	if m.HoldoutPercent <= 0 {
+		return false
+	}
+	return m.bucket("holdout", clientID) < uint64(m.HoldoutPercent)
+}
+
+// bucket returns reproducible bucket of client from 0 to 99 in mailing's split named by salt.
+// Splits with different salts, like holdout and A/B test cells, are independent of each other.
+func (m *Mailing) bucket(salt string, clientID int64) uint64 {
+	h := fnv.New64a()
+	h.Write([]byte(salt))
+	h.Write(m.ID[:])
+	h.Write(binary.BigEndian.AppendUint64(nil, uint64(clientID)))
+	return mix(h.Sum64()) % 100
+}
+
+// ValidateSegments checks that text and variants fit into MaxSegments.
+// Placeholders are counted as written, since personalized text is known only when message is sent.
+func (m *Mailing) ValidateSegments() error {
+	if m.MaxSegments == 0 {
+		return nil
+	}
+	if analysis := AnalyzeText(m.Text); analysis.Segments > m.MaxSegments {
+		return errors.Errorf("text takes %d SMS segments in %s, more than %d allowed",
+			analysis.Segments, analysis.Encoding, m.MaxSegments)
+	}
+	locales := []string{}
+	for locale := range m.Variants {
+		locales = append(locales, locale)
+	}
+	sort.Strings(locales)
+	for _, locale := range locales {
+		if analysis := AnalyzeText(m.Variants[locale]); analysis.Segments > m.MaxSegments {
+			return errors.Errorf("text of variant %q takes %d SMS segments in %s, more than %d allowed",
+				locale, analysis.Segments, analysis.Encoding, m.MaxSegments)
+		}
+	}
+	if m.ABTest != nil {
+		for i, text := range m.ABTest.Variants {
+			if analysis := AnalyzeText(text); analysis.Segments > m.MaxSegments {
+				return errors.Errorf("text of A/B variant %d takes %d SMS segments in %s, more than %d allowed",
+					i+2, analysis.Segments, analysis.Encoding, m.MaxSegments)
+			}
+		}
+	}
+	return nil
+}
+
+// ValidateABTest checks that A/B test can be run in mailing.
+func (m *Mailing) ValidateABTest() error {
+	if m.ABTest == nil {
+		return nil
+	}
+	if m.AudienceMode == AudienceModeDynamic {
+		return errors.New("A/B test can't be run in dynamic audience mode")
+	}
+	if len(m.Variants) > 0 {
+		return errors.New("A/B test can't be run in mailing with localized variants")
+	}
+	if !m.StartTime.Add(m.ABTest.Wait).Before(m.EndTime) {
+		return errors.New("A/B test must finish before mailing's end time")
+	}
+	return nil
+}
+
+type AudienceMode string
+
+const (
+	// AudienceModeStart resolves audience once when mailing starts.
+	AudienceModeStart AudienceMode = "start"
+	// AudienceModeSnapshot freezes audience when mailing is created and stores it for auditability.
+	// Later changes of clients, filter or segment don't affect the audience.
+	AudienceModeSnapshot AudienceMode = "snapshot"
+	// AudienceModeDynamic keeps audience live until mailing's end time,
+	// so clients matched after the start also receive the message.
+	AudienceModeDynamic AudienceMode = "dynamic"
+)
+
 type MailingStatus int
 
 const (
@@ -33,6 +146,10 @@ const (
 	MailingStatusInvalid MailingStatus = 5
 	// MailingStatusNotAMailing means that object is not a mailing, but a dependency for sending standalone messages.
 	MailingStatusNotAMailing MailingStatus = 6
+	// MailingStatusBudgetExceeded is mailing's status if mailing was stopped, because the next message would exceed it's budget.
+	MailingStatusBudgetExceeded MailingStatus = 7
+	// MailingStatusTesting is mailing's status if A/B test is sent and mailing waits for the rollout of the winner.
+	MailingStatusTesting MailingStatus = 8
 )
 
 // MailingUpdate is a struct with updates which should be applied to Mailing.
@@ -41,18 +158,54 @@ type MailingUpdate struct {
 	Filter    *Filter
 	StartTime time.Time
 	EndTime   time.Time
+	TTL       time.Duration
+	// SegmentID is the id of segment mailing should be sent to, nil means no change and 0 detaches segment.
+	SegmentID *int64
+	// TemplateID and TemplateVersion reference template version new text was copied from, nil if text is given as is.
+	// They are applied only along with text.
+	TemplateID      *int64
+	TemplateVersion int
+	// Variants replace all localized texts, nil means no change.
+	Variants map[string]string
+	// MaxSegments is nil if limit doesn't change, 0 removes limit.
+	MaxSegments *int
+	// RequireConsent is nil if requirement doesn't change.
+	RequireConsent *bool
+	// HoldoutPercent is nil if percentage doesn't change.
+	HoldoutPercent *int
+	// ABTest is nil if A/B test doesn't change, A/B test without variants removes it.
+	ABTest *ABTest
+	// SamplePercent, MaxRecipients and SampleSeed are nil if they don't change.
+	SamplePercent *int
+	MaxRecipients *int
+	SampleSeed    *int64
+	// Budget is nil if budget doesn't change, 0 removes limit.
+	Budget *int64
 }
 
 // MailingStats is a struct with common mailing statistic.
 type MailingStats struct {
 	// ID is mailing's id
 	ID uuid.UUID `db:"mailing_id"`
+	// Run is the number of mailing's run, 0 is the original run and the rest are follow-ups.
+	Run int `db:"run"`
 	// Matches is the amount of clients, that were matched to filter during mailing execution.
 	Matches int `db:"matches"`
 	// Sent is the amount of messages, that tried to send, no matter the success.
 	Sent int `db:"sent"`
 	// Fails is the amount of messages, that tried to send, but failed.
 	Fails int `db:"fails"`
+	// Expired is the amount of messages, that became stale before they were sent.
+	Expired int `db:"expired"`
+	// Suppressed is the amount of clients in audience, that were skipped, because they are in suppression list.
+	// Follow-up runs skip suppressed clients too, but don't count them.
+	Suppressed int `db:"suppressed"`
+	// Skipped is the amount of messages, that weren't sent because of frequency caps or because text couldn't be rendered.
+	Skipped int `db:"skipped"`
+	// HeldOut is the amount of matched clients, that belong to mailing's holdout group and weren't sent to.
+	HeldOut int `db:"held_out"`
+	// Cost is the total cost of messages, that tried to send, in minor currency units.
+	Cost int64 `db:"cost"`
 	// StartTime is the mailing start time.
 	StartTime time.Time `db:"start_time"`
 	// TimeExecuting is the duration of executing the mailing.
@@ -62,6 +215,22 @@ type MailingStats struct {
 // DetailedStats is a struct with detailed mailing statistic.
 type DetailedMailingStats struct {
 	CommonStats MailingStats
-	Attributes  Mailing
-	Messages    []Message
+	// FollowUps are stats of follow-up runs of mailing.
+	FollowUps  []MailingStats
+	Attributes Mailing
+	Messages   []Message
+	// Locales are stats of messages by locale of text variant sent.
+	Locales []LocaleStats
+	Clicks  ClickStats
+	// Variants are stats of A/B test variants in test stage, empty if mailing has no A/B test.
+	Variants []VariantStats
+}
+
+// LocaleStats is a statistic of mailing's messages sent in one locale.
+type LocaleStats struct {
+	// Locale is the locale of text variant, empty for default text.
+	Locale   string `db:"locale"`
+	Messages int    `db:"messages"`
+	Success  int    `db:"success"`
+	Fails    int    `db:"fails"`
 }
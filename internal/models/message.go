@@ -13,6 +13,34 @@ type Message struct {
 	MailingID uuid.UUID  `db:"mailing_id"`
 	ClientID  int64      `db:"client_id"`
 	Status    SendStatus `db:"status"`
+	LastError string     `db:"last_error"`
+	// SkipReason tells why message was skipped instead of being sent, see SendStatusSkipped.
+	SkipReason string `db:"skip_reason"`
+	// Run is the number of mailing's run the message was sent in.
+	Run int `db:"run"`
+	// ExpiresAt is the time after which message is stale and should not be sent, nil if message never expires.
+	ExpiresAt *time.Time `db:"expires_at"`
+	// Text is the text sent to client, personalized if it is a mailing's message.
+	Text string `db:"text"`
+	// Variant is the number of A/B test variant sent, 0 if mailing has no A/B test.
+	Variant int `db:"variant"`
+	// Rollout reports whether message is part of A/B test winner's rollout, that is sent in run 0 after the test.
+	Rollout bool `db:"rollout"`
+	// Locale is the locale of mailing's text variant sent, empty if default text was sent.
+	Locale string `db:"locale"`
+	// Segments is the amount of SMS segments text takes, see AnalyzeText.
+	Segments int `db:"segments"`
+	// Cost is the price of message in minor currency units: amount of segments times the price of segment.
+	Cost int64 `db:"cost"`
+	// Clicks is the amount of clicks on short links of the message.
+	Clicks int `db:"clicks"`
+	// Links are short links replacing URLs in text, they are saved along with the message.
+	Links []*Link `db:"-"`
+}
+
+// Expired reports whether message is stale at given time.
+func (m *Message) Expired(now time.Time) bool {
+	return m.ExpiresAt != nil && now.After(*m.ExpiresAt)
 }
 
 type SendStatus int
@@ -24,4 +52,9 @@ const (
 	SendStatusSuccess SendStatus = 1
 	// SendStatusFailed is a message's status if a error occured while sending message.
 	SendStatusFailed SendStatus = 2
+	// SendStatusExpired is a message's status if message became stale before it was sent.
+	SendStatusExpired SendStatus = 3
+	// SendStatusSkipped is a message's status if message wasn't sent, because client got too many messages recently
+	// or because text couldn't be rendered for client, see Message.SkipReason.
+	SendStatusSkipped SendStatus = 4
 )
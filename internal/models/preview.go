@@ -0,0 +1,40 @@
+package models
+
+import "time"
+
+// MailingPreview is a dry run of mailing: it's audience and messages, that would be sent.
+type MailingPreview struct {
+	// Matches is the amount of clients matching mailing's filter and segment, that would receive the message.
+	Matches int
+	// Suppressed is the amount of clients matching mailing, that are excluded, because they are in suppression list.
+	Suppressed int
+	// SampledOut is the amount of clients matching mailing, that are left out of it's sample.
+	// Preview samples the same clients as mailing only if SampleSeed is set, since mailing has no id yet.
+	SampledOut int
+	// ByPhoneOperator, ByTimezone and ByTag break matched clients down by their attributes.
+	// Client with many tags is counted under each of them.
+	ByPhoneOperator map[int]int
+	ByTimezone      map[string]int
+	ByTag           map[string]int
+	// ByLocale breaks matched clients down by locale of text variant they would get, empty for default text.
+	ByLocale map[string]int
+	// Samples are messages rendered for first matched clients.
+	Samples []*PreviewMessage
+	// RateLimit is the maximum amount of messages sent per second, 0 means no limit.
+	RateLimit int
+	// EstimatedDuration is the time sending all messages takes at rate limit, 0 if sending is not limited.
+	EstimatedDuration time.Duration
+	// FitsWindow tells whether all messages could be sent before mailing's end time.
+	FitsWindow bool
+}
+
+// PreviewMessage is a message, that would be sent to client.
+type PreviewMessage struct {
+	ClientID    int64
+	PhoneNumber int64
+	Text        string
+	Locale      string
+	Segments    int
+	// Error tells why text can't be rendered for client, such message is skipped when mailing runs.
+	Error string
+}
@@ -0,0 +1,35 @@
+package models
+
+// Price is the price of one SMS segment sent to phone operator via provider.
+type Price struct {
+	ID int64 `db:"id"`
+	// PhoneOperator is the operator code price applies to, 0 for any operator.
+	PhoneOperator int `db:"phone_operator"`
+	// Provider is the name of SMS provider price applies to, empty for any provider.
+	Provider string `db:"provider"`
+	// Price is the price of one segment in minor currency units, e.g. cents.
+	Price int64 `db:"price"`
+}
+
+// FindPrice returns price of one segment sent to phone operator via provider, 0 if no price matches.
+// The most specific price wins: operator's price for provider, then operator's price for any provider,
+// then provider's price for any operator and at last the default price.
+func FindPrice(prices []*Price, phoneOperator int, provider string) int64 {
+	price, best := int64(0), -1
+	for _, p := range prices {
+		if (p.PhoneOperator != 0 && p.PhoneOperator != phoneOperator) || (p.Provider != "" && p.Provider != provider) {
+			continue
+		}
+		specificity := 0
+		if p.PhoneOperator != 0 {
+			specificity += 2
+		}
+		if p.Provider != "" {
+			specificity++
+		}
+		if specificity > best {
+			price, best = p.Price, specificity
+		}
+	}
+	return price
+}
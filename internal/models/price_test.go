@@ -0,0 +1,54 @@
+package models
+
+import "testing"
+
+func TestFindPrice(t *testing.T) {
+	prices := []*Price{
+		{PhoneOperator: 0, Provider: "", Price: 1},
+		{PhoneOperator: 0, Provider: "twilio", Price: 2},
+		{PhoneOperator: 900, Provider: "", Price: 3},
+		{PhoneOperator: 900, Provider: "twilio", Price: 4},
+		{PhoneOperator: 901, Provider: "other", Price: 5},
+	}
+	tests := []struct {
+		name          string
+		prices        []*Price
+		phoneOperator int
+		provider      string
+		want          int64
+	}{
+		{name: "no prices", prices: nil, phoneOperator: 900, provider: "twilio", want: 0},
+		{name: "operator's price for provider", prices: prices, phoneOperator: 900, provider: "twilio", want: 4},
+		{name: "operator's price for any provider", prices: prices, phoneOperator: 900, provider: "other", want: 3},
+		{name: "provider's price for any operator", prices: prices, phoneOperator: 902, provider: "twilio", want: 2},
+		{name: "default price", prices: prices, phoneOperator: 902, provider: "other", want: 1},
+		{name: "no provider configured", prices: prices, phoneOperator: 900, provider: "", want: 3},
+		{name: "other provider's price doesn't apply", prices: prices, phoneOperator: 901, provider: "twilio", want: 2},
+		{
+			name: "operator beats provider",
+			prices: []*Price{
+				{PhoneOperator: 0, Provider: "twilio", Price: 2},
+				{PhoneOperator: 900, Provider: "", Price: 3},
+			},
+			phoneOperator: 900, provider: "twilio", want: 3,
+		},
+		{
+			name:          "free price",
+			prices:        []*Price{{Price: 1}, {PhoneOperator: 900, Price: 0}},
+			phoneOperator: 900, want: 0,
+		},
+		{
+			name:          "no matching price",
+			prices:        []*Price{{PhoneOperator: 900, Price: 3}},
+			phoneOperator: 901, want: 0,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := FindPrice(tt.prices, tt.phoneOperator, tt.provider)
+			if got != tt.want {
+				t.Errorf("FindPrice(%d, %q) = %d, want %d", tt.phoneOperator, tt.provider, got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,66 @@
+package models
+
+import (
+	"encoding/binary"
+	"hash/fnv"
+	"sort"
+)
+
+// seed returns seed of mailing's audience sample, derived from mailing's id unless SampleSeed is set.
+func (m *Mailing) seed() int64 {
+	if m.SampleSeed != 0 {
+		return m.SampleSeed
+	}
+	return int64(binary.BigEndian.Uint64(m.ID[:8]))
+}
+
+// sampleRank returns random, but reproducible with the same seed, rank of client in mailing's sample.
+func (m *Mailing) sampleRank(clientID int64) uint64 {
+	h := fnv.New64a()
+	h.Write(binary.BigEndian.AppendUint64(nil, uint64(m.seed())))
+	h.Write(binary.BigEndian.AppendUint64(nil, uint64(clientID)))
+	return mix(h.Sum64())
+}
+
+// mix is the finalizer of splitmix64. FNV barely changes high bits for different last bytes,
+// so hash is mixed before it is used for ordering.
+func mix(x uint64) uint64 {
+	x ^= x >> 30
+	x *= 0xbf58476d1ce4e5b9
+	x ^= x >> 27
+	x *= 0x94d049bb133111eb
+	x ^= x >> 31
+	return x
+}
+
+// Sample returns random SamplePercent of clients, at most limit of them if limit is positive, in original order.
+// Client in sample stays in it as long as the seed is the same.
+func (m *Mailing) Sample(clients []*Client, limit int) []*Client {
+	if m.SamplePercent <= 0 && limit <= 0 {
+		return clients
+	}
+	sampled := []*Client{}
+	for _, client := range clients {
+		if m.SamplePercent <= 0 || m.sampleRank(client.ID)%100 < uint64(m.SamplePercent) {
+			sampled = append(sampled, client)
+		}
+	}
+	if limit <= 0 || len(sampled) <= limit {
+		return sampled
+	}
+	ranked := append([]*Client{}, sampled...)
+	sort.Slice(ranked, func(i, j int) bool {
+		return m.sampleRank(ranked[i].ID) < m.sampleRank(ranked[j].ID)
+	})
+	kept := map[int64]bool{}
+	for _, client := range ranked[:limit] {
+		kept[client.ID] = true
+	}
+	capped := make([]*Client, 0, limit)
+	for _, client := range sampled {
+		if kept[client.ID] {
+			capped = append(capped, client)
+		}
+	}
+	return capped
+}
@@ -0,0 +1,23 @@
+package models
+
+import "github.com/pkg/errors"
+
+// ErrSegmentNotFound is returned when segment with given id doesn't exist.
+var ErrSegmentNotFound = errors.New("segment not found")
+
+// ErrSegmentInUse is returned when segment can't be deleted, because mailings reference it.
+var ErrSegmentInUse = errors.New("segment is used by mailings")
+
+// Segment is a named reusable audience, that mailings may reference instead of embedding filter.
+type Segment struct {
+	ID     int64   `db:"id"`
+	Name   string  `db:"name"`
+	Filter *Filter `db:"filter"`
+}
+
+// SegmentUpdate is a struct with updates which should be applied to segment.
+type SegmentUpdate struct {
+	Name string
+	// Filter replaces segment's filter as a whole, nil means no change.
+	Filter *Filter
+}
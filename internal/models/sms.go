@@ -0,0 +1,94 @@
+package models
+
+// TextEncoding is the encoding text is sent in as SMS.
+type TextEncoding string
+
+const (
+	// TextEncodingGSM7 is the default SMS alphabet, 7 bits per character.
+	TextEncodingGSM7 TextEncoding = "GSM-7"
+	// TextEncodingUCS2 is used if text has characters outside of GSM-7 alphabet, 16 bits per character.
+	TextEncodingUCS2 TextEncoding = "UCS-2"
+)
+
+const (
+	gsm7Single    = 160
+	gsm7Multipart = 153
+	ucs2Single    = 70
+	ucs2Multipart = 67
+)
+
+// gsm7Basic is the GSM-7 basic character set, each character takes one septet.
+var gsm7Basic = charset("@£$¥èéùìòÇ\nØø\rÅåΔ_ΦΓΛΩΠΨΣΘΞÆæßÉ !\"#¤%&'()*+,-./0123456789:;<=>?" +
+	"¡ABCDEFGHIJKLMNOPQRSTUVWXYZÄÖÑÜ§¿abcdefghijklmnopqrstuvwxyzäöñüà")
+
+// gsm7Extension is the GSM-7 extension table, each character takes two septets.
+var gsm7Extension = charset("\f^{}\\[~]|€")
+
+func charset(chars string) map[rune]bool {
+	set := map[rune]bool{}
+	for _, r := range chars {
+		set[r] = true
+	}
+	return set
+}
+
+// TextAnalysis describes how text is sent as SMS.
+type TextAnalysis struct {
+	Encoding TextEncoding
+	// Length is the length of text in units of encoding: septets for GSM-7, 16-bit code units for UCS-2.
+	Length int
+	// Segments is the amount of SMS text is split into, 0 for empty text.
+	Segments int
+	// UnicodeCharacters are distinct characters outside of GSM-7 alphabet in order of appearance,
+	// any of them forces UCS-2 encoding.
+	UnicodeCharacters []string
+}
+
+// AnalyzeText returns encoding, length and amount of segments of text sent as SMS.
+// Characters are never split between segments, so multipart text may take more segments than length suggests.
+func AnalyzeText(text string) *TextAnalysis {
+	analysis := &TextAnalysis{Encoding: TextEncodingGSM7, UnicodeCharacters: []string{}}
+	seen := map[rune]bool{}
+	for _, r := range text {
+		if !gsm7Basic[r] && !gsm7Extension[r] && !seen[r] {
+			seen[r] = true
+			analysis.UnicodeCharacters = append(analysis.UnicodeCharacters, string(r))
+		}
+	}
+	single, multipart := gsm7Single, gsm7Multipart
+	if len(analysis.UnicodeCharacters) > 0 {
+		analysis.Encoding = TextEncodingUCS2
+		single, multipart = ucs2Single, ucs2Multipart
+	}
+	units := []int{}
+	for _, r := range text {
+		size := 1
+		switch {
+		case analysis.Encoding == TextEncodingGSM7 && gsm7Extension[r]:
+			// Extension character is an escape followed by the character.
+			size = 2
+		case analysis.Encoding == TextEncodingUCS2 && r > 0xFFFF:
+			// Characters outside of basic plane take a surrogate pair.
+			size = 2
+		}
+		units = append(units, size)
+		analysis.Length += size
+	}
+	if analysis.Length == 0 {
+		return analysis
+	}
+	if analysis.Length <= single {
+		analysis.Segments = 1
+		return analysis
+	}
+	analysis.Segments = 1
+	free := multipart
+	for _, size := range units {
+		if size > free {
+			analysis.Segments++
+			free = multipart
+		}
+		free -= size
+	}
+	return analysis
+}
@@ -0,0 +1,86 @@
+package models
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestAnalyzeText(t *testing.T) {
+	tests := []struct {
+		name     string
+		text     string
+		encoding TextEncoding
+		length   int
+		segments int
+		unicode  []string
+	}{
+		{name: "empty", text: "", encoding: TextEncodingGSM7},
+		{name: "basic", text: "Hello", encoding: TextEncodingGSM7, length: 5, segments: 1},
+		{name: "GSM-7 single limit", text: strings.Repeat("a", 160), encoding: TextEncodingGSM7, length: 160, segments: 1},
+		{name: "GSM-7 multipart", text: strings.Repeat("a", 161), encoding: TextEncodingGSM7, length: 161, segments: 2},
+		{name: "GSM-7 two full parts", text: strings.Repeat("a", 306), encoding: TextEncodingGSM7, length: 306, segments: 2},
+		{name: "GSM-7 three parts", text: strings.Repeat("a", 307), encoding: TextEncodingGSM7, length: 307, segments: 3},
+		{name: "extension takes two septets", text: "€", encoding: TextEncodingGSM7, length: 2, segments: 1},
+		{
+			name:     "extension fits single limit",
+			text:     strings.Repeat("a", 158) + "€",
+			encoding: TextEncodingGSM7, length: 160, segments: 1,
+		},
+		{
+			name:     "extension exceeds single limit",
+			text:     strings.Repeat("a", 159) + "€",
+			encoding: TextEncodingGSM7, length: 161, segments: 2,
+		},
+		{
+			// Escape and the character can't be split, so extension moves to the next segment.
+			name:     "extension at segment boundary",
+			text:     strings.Repeat("a", 152) + "€" + strings.Repeat("a", 152),
+			encoding: TextEncodingGSM7, length: 306, segments: 3,
+		},
+		{
+			name:     "extension ending segment",
+			text:     strings.Repeat("a", 151) + "€" + strings.Repeat("a", 153),
+			encoding: TextEncodingGSM7, length: 306, segments: 2,
+		},
+		{name: "unicode", text: "Привет", encoding: TextEncodingUCS2, length: 6, segments: 1,
+			unicode: []string{"П", "р", "и", "в", "е", "т"}},
+		{name: "unicode repeated", text: "ёё a", encoding: TextEncodingUCS2, length: 4, segments: 1,
+			unicode: []string{"ё"}},
+		{name: "UCS-2 single limit", text: strings.Repeat("ж", 70), encoding: TextEncodingUCS2, length: 70, segments: 1,
+			unicode: []string{"ж"}},
+		{name: "UCS-2 multipart", text: strings.Repeat("ж", 71), encoding: TextEncodingUCS2, length: 71, segments: 2,
+			unicode: []string{"ж"}},
+		{name: "extension in UCS-2 takes one unit", text: "ж€", encoding: TextEncodingUCS2, length: 2, segments: 1,
+			unicode: []string{"ж"}},
+		{name: "surrogate pair", text: "😀", encoding: TextEncodingUCS2, length: 2, segments: 1,
+			unicode: []string{"😀"}},
+		{
+			name:     "surrogate pair fits single limit",
+			text:     strings.Repeat("a", 68) + "😀",
+			encoding: TextEncodingUCS2, length: 70, segments: 1, unicode: []string{"😀"},
+		},
+		{
+			// Surrogate pair can't be split, so it moves to the next segment.
+			name:     "surrogate pair at segment boundary",
+			text:     strings.Repeat("a", 66) + "😀" + strings.Repeat("a", 66),
+			encoding: TextEncodingUCS2, length: 134, segments: 3, unicode: []string{"😀"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := AnalyzeText(tt.text)
+			if got.Encoding != tt.encoding || got.Length != tt.length || got.Segments != tt.segments {
+				t.Errorf("AnalyzeText() = %s, %d units, %d segments; want %s, %d units, %d segments",
+					got.Encoding, got.Length, got.Segments, tt.encoding, tt.length, tt.segments)
+			}
+			unicode := tt.unicode
+			if unicode == nil {
+				unicode = []string{}
+			}
+			if !reflect.DeepEqual(got.UnicodeCharacters, unicode) {
+				t.Errorf("UnicodeCharacters = %q, want %q", got.UnicodeCharacters, unicode)
+			}
+		})
+	}
+}
@@ -0,0 +1,35 @@
+package models
+
+import (
+	"testing"
+
+	"github.com/google/uuid"
+)
+
+func TestSplitsAreIndependent(t *testing.T) {
+	const clients = 100000
+	m := &Mailing{
+		ID:             uuid.MustParse("8f2d6c1e-4b7a-4c3e-9a5d-2e1f0b3c4d5e"),
+		HoldoutPercent: 10,
+		ABTest:         &ABTest{Variants: []string{"b", "c"}, CellPercent: 10},
+	}
+	heldOut := map[int]int{}
+	cells := map[int]int{}
+	for id := int64(1); id <= clients; id++ {
+		cell := m.TestCell(id)
+		cells[cell]++
+		if m.HeldOut(id) {
+			heldOut[cell]++
+		}
+	}
+	for variant := 1; variant <= 3; variant++ {
+		// About 10% of clients in each cell, with a wide margin for randomness.
+		if share := float64(cells[variant]) / clients; share < 0.09 || share > 0.11 {
+			t.Errorf("cell %d has %.3f of clients, want about 0.1", variant, share)
+		}
+		// Holdout takes about 10% of every cell, as if it was chosen independently.
+		if share := float64(heldOut[variant]) / float64(cells[variant]); share < 0.08 || share > 0.12 {
+			t.Errorf("holdout takes %.3f of cell %d, want about 0.1", share, variant)
+		}
+	}
+}
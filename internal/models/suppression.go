@@ -0,0 +1,37 @@
+package models
+
+import (
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+// ErrClientSuppressed is returned when message is sent to client, that opted out.
+var ErrClientSuppressed = errors.New("client is suppressed")
+
+// ErrSuppressionNotFound is returned when suppression list entry with given id doesn't exist.
+var ErrSuppressionNotFound = errors.New("suppression not found")
+
+// ErrAlreadySuppressed is returned when client or phone number is already in suppression list.
+var ErrAlreadySuppressed = errors.New("client or phone number is already suppressed")
+
+// Suppression is an entry of suppression list, clients matching it never receive messages.
+// It matches client by id or by phone number, so clients added later with the same number are suppressed too.
+type Suppression struct {
+	ID int64 `db:"id"`
+	// ClientID and PhoneNumber are nil if suppression doesn't match by them, at least one of them is set.
+	ClientID    *int64 `db:"client_id"`
+	PhoneNumber *int64 `db:"phone_number"`
+	// Reason is a free form explanation, e.g. "asked to stop by phone".
+	Reason string `db:"reason"`
+	// Source tells where suppression came from, e.g. SuppressionSourceManual.
+	Source    string    `db:"source"`
+	CreatedAt time.Time `db:"created_at"`
+}
+
+const (
+	// SuppressionSourceManual is the source of suppressions added by operators.
+	SuppressionSourceManual = "manual"
+	// SuppressionSourceInbound is the source of suppressions added when client replied with STOP keyword.
+	SuppressionSourceInbound = "inbound"
+)
@@ -0,0 +1,31 @@
+package models
+
+import (
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+// ErrTemplateNotFound is returned when template or it's version doesn't exist.
+var ErrTemplateNotFound = errors.New("template not found")
+
+// Template is a named message text with version history.
+// Versions are immutable, so mailings copying template's text keep what they were created with.
+type Template struct {
+	ID   int64  `db:"id"`
+	Name string `db:"name"`
+	// Version is the number of the latest version, versions are numbered from 1.
+	Version int `db:"version"`
+	// Text is the text of the latest version.
+	Text string `db:"text"`
+	// UpdatedAt is the time the latest version was created.
+	UpdatedAt time.Time `db:"updated_at"`
+}
+
+// TemplateVersion is a version of template's text.
+type TemplateVersion struct {
+	TemplateID int64     `db:"template_id"`
+	Version    int       `db:"version"`
+	Text       string    `db:"text"`
+	CreatedAt  time.Time `db:"created_at"`
+}